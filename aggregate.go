@@ -32,6 +32,8 @@ type Aggregate interface {
 
 	All(result interface{}) error
 
+	Explain(verbosity ExplainVerbosity) (*ExplainResult, error)
+
 	Cursor() Cursor
 }
 
@@ -41,6 +43,7 @@ type aggregate struct {
 	ctx        context.Context
 	opts       *options.AggregateOptions
 	aggregator aggregator
+	invoker    invoker
 }
 
 func (ag *aggregate) AllowDiskUse(b bool) Aggregate {
@@ -99,6 +102,11 @@ func (ag *aggregate) All(result interface{}) error {
 }
 
 func (ag *aggregate) Cursor() Cursor {
-	var cur, err = ag.aggregator.Aggregate(ag.ctx, ag.pipeline, ag.opts)
+	var cur *mongo.Cursor
+	var op = &Operation{Name: OpAggregate, Pipeline: ag.pipeline}
+	var err = ag.invoker.invoke(ag.ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		cur, err = ag.aggregator.Aggregate(ctx, op.Pipeline, ag.opts)
+		return err
+	})
 	return &cursor{Cursor: cur, err: err}
 }
@@ -2,8 +2,12 @@ package dbm
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
 )
 
 type WriteModel = mongo.WriteModel
@@ -49,8 +53,17 @@ type Bulk interface {
 
 	BypassDocumentValidation(bypass bool) Bulk
 
+	// ChunkSize 设置每次 BulkWrite 最多发送的操作数量，小于等于 0 时一次发送所有操作
+	ChunkSize(size int) Bulk
+
+	// Concurrency 设置同时执行的 chunk 数量，只对无序执行的 Bulk 有效，有序执行时 chunk 总是依次执行
+	Concurrency(n int) Bulk
+
 	AddModel(m WriteModel) Bulk
 
+	// Tag 为最后一个添加的操作设置标识，可以通过 BulkResult.Operations 获取该操作的执行结果
+	Tag(tag interface{}) Bulk
+
 	InsertOne(document interface{}) Bulk
 
 	InsertOneNx(filter interface{}, document interface{}) Bulk
@@ -77,13 +90,30 @@ type Bulk interface {
 
 	DeleteMany(filter interface{}) Bulk
 
+	// Len 返回还没有执行的操作数量
+	Len() int
+
+	// Reset 清空还没有执行的操作，保留 Ordered 等选项
+	Reset() Bulk
+
+	// Models 返回还没有执行的操作
+	Models() []WriteModel
+
+	// Describe 将还没有执行的操作转换为 extended JSON，用于输出日志和测试，不会执行这些操作
+	Describe() ([]string, error)
+
+	// Apply 执行所有操作，执行成功的操作会被清空，所以多次调用 Apply 不会重复执行同一个操作；
+	// 发生错误时状态不是 BulkSucceeded 的操作及其 Tag 会被保留，可以再次调用 Apply 重试这些操作，或者调用 Reset 放弃这些操作
 	Apply(ctx context.Context) (*BulkResult, error)
 }
 
 type bulk struct {
-	models     []mongo.WriteModel
-	opts       *options.BulkWriteOptions
-	collection Collection
+	models      []mongo.WriteModel
+	tags        []interface{}
+	opts        *options.BulkWriteOptions
+	collection  *collection
+	chunkSize   int
+	concurrency int
 }
 
 func (b *bulk) Ordered(ordered bool) Bulk {
@@ -96,9 +126,27 @@ func (b *bulk) BypassDocumentValidation(bypass bool) Bulk {
 	return b
 }
 
+func (b *bulk) ChunkSize(size int) Bulk {
+	b.chunkSize = size
+	return b
+}
+
+func (b *bulk) Concurrency(n int) Bulk {
+	b.concurrency = n
+	return b
+}
+
 func (b *bulk) AddModel(m WriteModel) Bulk {
 	if m != nil {
 		b.models = append(b.models, m)
+		b.tags = append(b.tags, nil)
+	}
+	return b
+}
+
+func (b *bulk) Tag(tag interface{}) Bulk {
+	if len(b.tags) > 0 {
+		b.tags[len(b.tags)-1] = tag
 	}
 	return b
 }
@@ -186,7 +234,296 @@ func (b *bulk) DeleteMany(filter interface{}) Bulk {
 	return b.AddModel(m)
 }
 
-func (b *bulk) Apply(ctx context.Context) (*BulkResult, error) {
-	var result, err = b.collection.Collection().BulkWrite(ctx, b.models, b.opts)
-	return result, err
+func (b *bulk) Len() int {
+	return len(b.models)
+}
+
+func (b *bulk) Reset() Bulk {
+	b.models = nil
+	b.tags = nil
+	return b
+}
+
+func (b *bulk) Models() []WriteModel {
+	var models = make([]WriteModel, len(b.models))
+	copy(models, b.models)
+	return models
+}
+
+func (b *bulk) Describe() ([]string, error) {
+	var registry = b.collection.database.Client().Registry()
+	var descriptions = make([]string, 0, len(b.models))
+	for _, model := range b.models {
+		var data, err = bson.MarshalExtJSONWithRegistry(registry, describeModel(model), false, false)
+		if err != nil {
+			return nil, err
+		}
+		descriptions = append(descriptions, string(data))
+	}
+	return descriptions, nil
+}
+
+// describeModel 将 WriteModel 转换为与 mongo shell 中 bulkWrite 相同格式的文档
+func describeModel(model WriteModel) bson.D {
+	var name string
+	var fields bson.D
+	switch m := model.(type) {
+	case *InsertOneModel:
+		name = "insertOne"
+		fields = bson.D{{Key: "document", Value: m.Document}}
+	case *UpdateOneModel:
+		name = "updateOne"
+		fields = bson.D{{Key: "filter", Value: m.Filter}, {Key: "update", Value: m.Update}}
+		fields = appendModelOptions(fields, m.Upsert, m.ArrayFilters, m.Collation, m.Hint)
+	case *UpdateManyModel:
+		name = "updateMany"
+		fields = bson.D{{Key: "filter", Value: m.Filter}, {Key: "update", Value: m.Update}}
+		fields = appendModelOptions(fields, m.Upsert, m.ArrayFilters, m.Collation, m.Hint)
+	case *ReplaceOneModel:
+		name = "replaceOne"
+		fields = bson.D{{Key: "filter", Value: m.Filter}, {Key: "replacement", Value: m.Replacement}}
+		fields = appendModelOptions(fields, m.Upsert, nil, m.Collation, m.Hint)
+	case *DeleteOneModel:
+		name = "deleteOne"
+		fields = bson.D{{Key: "filter", Value: m.Filter}}
+		fields = appendModelOptions(fields, nil, nil, m.Collation, m.Hint)
+	case *DeleteManyModel:
+		name = "deleteMany"
+		fields = bson.D{{Key: "filter", Value: m.Filter}}
+		fields = appendModelOptions(fields, nil, nil, m.Collation, m.Hint)
+	default:
+		return bson.D{{Key: "unknown", Value: fmt.Sprintf("%T", model)}}
+	}
+	return bson.D{{Key: name, Value: fields}}
+}
+
+func appendModelOptions(fields bson.D, upsert *bool, arrayFilters *options.ArrayFilters, collation *options.Collation, hint interface{}) bson.D {
+	if upsert != nil {
+		fields = append(fields, bson.E{Key: "upsert", Value: *upsert})
+	}
+	if arrayFilters != nil {
+		fields = append(fields, bson.E{Key: "arrayFilters", Value: arrayFilters.Filters})
+	}
+	if collation != nil {
+		fields = append(fields, bson.E{Key: "collation", Value: collation.ToDocument()})
+	}
+	if hint != nil {
+		fields = append(fields, bson.E{Key: "hint", Value: hint})
+	}
+	return fields
+}
+
+// Apply 执行所有操作，发生错误时返回的 BulkResult 中包含每个操作的执行结果。
+//
+// 设置了 ChunkSize 时分多次执行，返回合并之后的结果，错误中操作的下标为其在整个 Bulk 中的下标。
+func (b *bulk) Apply(ctx context.Context) (result *BulkResult, err error) {
+	if len(b.models) == 0 {
+		return nil, ErrEmptyBulk
+	}
+
+	var models, tags = b.models, b.tags
+	b.Reset()
+	defer func() {
+		if err != nil {
+			b.retain(result)
+		}
+	}()
+
+	var ordered = b.opts.Ordered == nil || *b.opts.Ordered
+	if b.chunkSize <= 0 || len(models) <= b.chunkSize {
+		return b.apply(ctx, models, tags, ordered)
+	}
+
+	var chunks [][2]int
+	for start := 0; start < len(models); start += b.chunkSize {
+		var end = start + b.chunkSize
+		if end > len(models) {
+			end = len(models)
+		}
+		chunks = append(chunks, [2]int{start, end})
+	}
+
+	var results = make([]*BulkResult, len(chunks))
+	var errs = make([]error, len(chunks))
+	if ordered || b.concurrency <= 1 {
+		for i, chunk := range chunks {
+			results[i], errs[i] = b.apply(ctx, models[chunk[0]:chunk[1]], tags[chunk[0]:chunk[1]], ordered)
+			if errs[i] != nil && ordered {
+				break
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		var sem = make(chan struct{}, b.concurrency)
+		for i, chunk := range chunks {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int, chunk [2]int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				results[i], errs[i] = b.apply(ctx, models[chunk[0]:chunk[1]], tags[chunk[0]:chunk[1]], ordered)
+			}(i, chunk)
+		}
+		wg.Wait()
+	}
+	return mergeBulkResults(chunks, results, errs, models, tags)
+}
+
+// retain 将执行结果中状态不是 BulkSucceeded 的操作放回 Bulk 中
+func (b *bulk) retain(result *BulkResult) {
+	if result == nil {
+		return
+	}
+	var models = make([]WriteModel, 0, len(result.Operations))
+	var tags = make([]interface{}, 0, len(result.Operations))
+	for _, op := range result.Operations {
+		if op.Status != BulkSucceeded {
+			models = append(models, op.Model)
+			tags = append(tags, op.Tag)
+		}
+	}
+	b.models = append(models, b.models...)
+	b.tags = append(tags, b.tags...)
+}
+
+func (b *bulk) apply(ctx context.Context, models []WriteModel, tags []interface{}, ordered bool) (*BulkResult, error) {
+	var sent []WriteModel
+	var result *mongo.BulkWriteResult
+	var op = &Operation{Name: OpBulkWrite, Models: models}
+	var err = b.collection.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		sent = op.Models
+		result, err = b.collection.collection.BulkWrite(ctx, op.Models, b.opts)
+		return err
+	})
+	if sent == nil {
+		sent = op.Models
+	}
+	return newBulkResult(sent, tags, ordered, result, err), err
+}
+
+// mergeBulkResults 合并各个 chunk 的执行结果，没有执行的 chunk 中的操作标记为 BulkSkipped
+func mergeBulkResults(chunks [][2]int, results []*BulkResult, errs []error, models []WriteModel, tags []interface{}) (*BulkResult, error) {
+	var nResult = &BulkResult{}
+	nResult.UpsertedIDs = make(map[int64]interface{})
+	nResult.Operations = make([]BulkOperationResult, 0, len(models))
+
+	var bErr mongo.BulkWriteException
+	var hasBulkErr bool
+	var otherErr error
+	for i, chunk := range chunks {
+		var offset = chunk[0]
+		var result = results[i]
+		if result == nil {
+			for j := chunk[0]; j < chunk[1]; j++ {
+				nResult.Operations = append(nResult.Operations, BulkOperationResult{Index: j, Tag: tags[j], Model: models[j], Status: BulkSkipped})
+			}
+			continue
+		}
+
+		nResult.InsertedCount += result.InsertedCount
+		nResult.MatchedCount += result.MatchedCount
+		nResult.ModifiedCount += result.ModifiedCount
+		nResult.DeletedCount += result.DeletedCount
+		nResult.UpsertedCount += result.UpsertedCount
+		for index, id := range result.UpsertedIDs {
+			nResult.UpsertedIDs[index+int64(offset)] = id
+		}
+		for _, op := range result.Operations {
+			op.Index += offset
+			switch opErr := op.Err.(type) {
+			case *DuplicateKeyError:
+				var nErr = *opErr
+				nErr.WriteIndex += offset
+				op.Err = &nErr
+			case mongo.BulkWriteError:
+				opErr.Index += offset
+				op.Err = opErr
+			}
+			nResult.Operations = append(nResult.Operations, op)
+		}
+
+		var err = errs[i]
+		if err == nil {
+			continue
+		}
+		var chunkErr mongo.BulkWriteException
+		if !errors.As(err, &chunkErr) {
+			if otherErr == nil {
+				otherErr = err
+			}
+			continue
+		}
+		hasBulkErr = true
+		for _, we := range chunkErr.WriteErrors {
+			we.Index += offset
+			bErr.WriteErrors = append(bErr.WriteErrors, we)
+		}
+		if bErr.WriteConcernError == nil {
+			bErr.WriteConcernError = chunkErr.WriteConcernError
+		}
+		bErr.Labels = append(bErr.Labels, chunkErr.Labels...)
+	}
+
+	// 优先返回网络错误等无法确定执行结果的错误
+	if otherErr != nil {
+		return nResult, otherErr
+	}
+	if hasBulkErr {
+		return nResult, bErr
+	}
+	return nResult, nil
+}
+
+func newBulkResult(models []WriteModel, tags []interface{}, ordered bool, result *mongo.BulkWriteResult, err error) *BulkResult {
+	var nResult = &BulkResult{}
+	if result != nil {
+		nResult.BulkWriteResult = *result
+	}
+
+	var status = BulkSucceeded
+	var failed = make(map[int]error)
+	var skipFrom = len(models)
+	if err != nil {
+		var bErr mongo.BulkWriteException
+		if errors.As(err, &bErr) {
+			for _, we := range bErr.WriteErrors {
+				failed[we.Index] = bulkWriteError(we)
+				if ordered && we.Index < skipFrom {
+					skipFrom = we.Index
+				}
+			}
+		} else {
+			// 没有返回 BulkWriteException 时无法确定每个操作的执行结果
+			status = BulkUnknown
+		}
+	}
+
+	nResult.Operations = make([]BulkOperationResult, len(models))
+	for i, model := range models {
+		var opResult = BulkOperationResult{Index: i, Model: model, Status: status}
+		if i < len(tags) {
+			opResult.Tag = tags[i]
+		}
+		if fErr, exists := failed[i]; exists {
+			opResult.Status = BulkFailed
+			opResult.Err = fErr
+		} else if status == BulkSucceeded && ordered && i > skipFrom {
+			opResult.Status = BulkSkipped
+		}
+		if id, exists := nResult.UpsertedIDs[int64(i)]; exists {
+			opResult.UpsertedID = id
+		}
+		nResult.Operations[i] = opResult
+	}
+	return nResult
+}
+
+// bulkWriteError 唯一索引冲突转换为 DuplicateKeyError，其它错误保持不变
+func bulkWriteError(we mongo.BulkWriteError) error {
+	if isDuplicateKeyCode(we.Code, we.Message) {
+		return newDuplicateKeyError(we.Index, we.Code, we.Message, we.Raw)
+	}
+	return we
 }
@@ -0,0 +1,68 @@
+package dbm
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestBulk_ApplyRetain(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("write error", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "n", Value: 1},
+			{Key: "writeErrors", Value: bson.A{bson.D{
+				{Key: "index", Value: 1},
+				{Key: "code", Value: 11000},
+				{Key: "errmsg", Value: "E11000 duplicate key error collection: test.user index: _id_ dup key: { _id: 1 }"},
+			}}},
+		})
+		var b = (&collection{collection: mt.Coll}).Bulk()
+		b.InsertOne(bson.D{{Key: "_id", Value: 0}}).Tag("row-1")
+		b.InsertOne(bson.D{{Key: "_id", Value: 1}}).Tag("row-2")
+		b.InsertOne(bson.D{{Key: "_id", Value: 2}}).Tag("row-3")
+
+		if _, err := b.Apply(context.Background()); !IsDuplicateKey(err) {
+			t.Fatal("应该返回 duplicate key 错误", err)
+		}
+		// 失败和跳过的操作会被保留
+		if b.Len() != 2 {
+			t.Fatal("保留的操作数量不匹配", b.Len())
+		}
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}})
+		var result, err = b.Apply(context.Background())
+		if err != nil {
+			t.Fatal("重试 Bulk 发生错误", err)
+		}
+		if len(result.Operations) != 2 || result.Operations[0].Tag != "row-2" || result.Operations[1].Tag != "row-3" {
+			t.Fatal("重试的操作不匹配", result.Operations)
+		}
+		if b.Len() != 0 {
+			t.Fatal("执行成功之后应该清空操作", b.Len())
+		}
+	})
+
+	mt.Run("command error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Name: "ShutdownInProgress", Message: "shutdown"}))
+		var b = (&collection{collection: mt.Coll}).Bulk().ChunkSize(1)
+		b.InsertOne(bson.D{{Key: "_id", Value: 0}}).Tag("row-1")
+		b.InsertOne(bson.D{{Key: "_id", Value: 1}}).Tag("row-2")
+
+		var result, err = b.Apply(context.Background())
+		if err == nil {
+			t.Fatal("应该返回错误")
+		}
+		if result.Operations[0].Status != BulkUnknown || result.Operations[1].Status != BulkSkipped {
+			t.Fatal("操作状态不匹配", result.Operations)
+		}
+		// 无法确定执行结果和没有执行的操作都会被保留
+		var models = b.Models()
+		if len(models) != 2 || b.(*bulk).tags[0] != "row-1" || b.(*bulk).tags[1] != "row-2" {
+			t.Fatal("保留的操作不匹配", len(models))
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package dbm_test
+
+import (
+	"context"
+	"errors"
+	"github.com/smartwalle/dbm"
+	"testing"
+)
+
+func TestBulk_ApplyOperations(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var uid1 = dbm.NewObjectId().Hex()
+	var uid2 = dbm.NewObjectId().Hex()
+
+	var bulk = tUser.Bulk()
+	bulk.InsertOne(&User{Id: uid1, Age: 10, Name: "Bulk1"}).Tag("row-1")
+	bulk.InsertOne(&User{Id: uid1, Age: 11, Name: "Bulk2"}).Tag("row-2")
+	bulk.InsertOne(&User{Id: uid2, Age: 12, Name: "Bulk3"}).Tag("row-3")
+
+	var result, err = bulk.Apply(context.Background())
+	if !dbm.IsDuplicateKey(err) {
+		t.Fatal("应该返回 duplicate key 错误", err)
+	}
+
+	if len(result.Operations) != 3 {
+		t.Fatal("操作结果数量不匹配", len(result.Operations))
+	}
+
+	var statuses = []dbm.BulkOperationStatus{dbm.BulkSucceeded, dbm.BulkFailed, dbm.BulkSkipped}
+	for i, op := range result.Operations {
+		if op.Status != statuses[i] {
+			t.Fatal("操作状态不匹配", i, op.Status)
+		}
+	}
+
+	var failed = result.Failed()
+	if len(failed) != 1 || failed[0].Tag != "row-2" {
+		t.Fatal("失败的操作不匹配", failed)
+	}
+	if _, ok := failed[0].Err.(*dbm.DuplicateKeyError); !ok {
+		t.Fatal("错误类型不匹配", failed[0].Err)
+	}
+}
+
+func TestBulk_ApplyChunks(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var bulk = tUser.Bulk().Ordered(false).ChunkSize(3).Concurrency(2)
+	for i := 0; i < 10; i++ {
+		bulk.InsertOne(&User{Id: dbm.NewObjectId().Hex(), Age: i, Name: "BulkChunk"}).Tag(i)
+	}
+
+	var result, err = bulk.Apply(context.Background())
+	if err != nil {
+		t.Fatal("执行 Bulk 发生错误", err)
+	}
+
+	if result.InsertedCount != 10 || len(result.Operations) != 10 {
+		t.Fatal("执行结果不匹配", result.InsertedCount, len(result.Operations))
+	}
+	for i, op := range result.Operations {
+		if op.Index != i || op.Tag != i || op.Status != dbm.BulkSucceeded {
+			t.Fatal("操作结果不匹配", op)
+		}
+	}
+}
+
+func TestBulk_Describe(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var bulk = tUser.Bulk()
+	if _, err := bulk.Apply(context.Background()); !errors.Is(err, dbm.ErrEmptyBulk) {
+		t.Fatal("应该返回 ErrEmptyBulk", err)
+	}
+
+	bulk.UpsertId("1", dbm.M{"$set": dbm.M{"age": 10}}).DeleteId("2")
+	if bulk.Len() != 2 || len(bulk.Models()) != 2 {
+		t.Fatal("操作数量不匹配", bulk.Len())
+	}
+
+	var descriptions, err = bulk.Describe()
+	if err != nil {
+		t.Fatal("转换操作发生错误", err)
+	}
+	var expected = []string{
+		`{"updateOne":{"filter":{"_id":"1"},"update":{"$set":{"age":10}},"upsert":true}}`,
+		`{"deleteOne":{"filter":{"_id":"2"}}}`,
+	}
+	for i, description := range descriptions {
+		if description != expected[i] {
+			t.Fatal("转换结果不匹配", description)
+		}
+	}
+
+	if bulk.Reset().Len() != 0 {
+		t.Fatal("Reset 之后应该没有操作")
+	}
+}
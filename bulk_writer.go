@@ -0,0 +1,149 @@
+package dbm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type BulkWriterOptions struct {
+	// Size 为每次写入的最大操作数量，默认为 1000
+	Size int
+
+	// Interval 为两次写入的最大间隔，没有达到 Size 时也会在 Interval 之后写入，默认为 1 秒
+	Interval time.Duration
+
+	// Ordered 为 true 时有序执行，默认为无序执行
+	Ordered bool
+
+	// OnFlush 在每次写入之后调用，返回错误时 BulkWriter 停止运行；为 nil 时写入发生错误 BulkWriter 即停止运行
+	OnFlush func(ctx context.Context, result *BulkResult, err error) error
+}
+
+func NewBulkWriterOptions() *BulkWriterOptions {
+	return &BulkWriterOptions{}
+}
+
+func (opts *BulkWriterOptions) SetSize(size int) *BulkWriterOptions {
+	opts.Size = size
+	return opts
+}
+
+func (opts *BulkWriterOptions) SetInterval(interval time.Duration) *BulkWriterOptions {
+	opts.Interval = interval
+	return opts
+}
+
+func (opts *BulkWriterOptions) SetOrdered(ordered bool) *BulkWriterOptions {
+	opts.Ordered = ordered
+	return opts
+}
+
+func (opts *BulkWriterOptions) SetOnFlush(fn func(ctx context.Context, result *BulkResult, err error) error) *BulkWriterOptions {
+	opts.OnFlush = fn
+	return opts
+}
+
+// BulkWriter 从 channel 中读取操作，按照数量或者时间间隔批量写入 Collection。
+type BulkWriter interface {
+	// Run 持续读取并写入操作，直到 models 被关闭、ctx 被取消或者写入发生错误；models 被关闭时会写入剩余的操作。
+	//
+	// ctx 被取消时不会再写入剩余的操作，已经读取但是还没有写入的操作通过 *UnflushedError 返回，由调用者决定如何处理。
+	Run(ctx context.Context, models <-chan WriteModel) error
+}
+
+// UnflushedError 在 BulkWriter 的 ctx 被取消时返回，Models 为已经从 channel 中读取但是还没有写入的操作。
+type UnflushedError struct {
+	Models []WriteModel
+	Err    error
+}
+
+func (e *UnflushedError) Error() string {
+	return fmt.Sprintf("dbm: %d write models not flushed: %v", len(e.Models), e.Err)
+}
+
+func (e *UnflushedError) Unwrap() error {
+	return e.Err
+}
+
+type bulkWriter struct {
+	collection Collection
+	opts       *BulkWriterOptions
+}
+
+func NewBulkWriter(collection Collection, opts ...*BulkWriterOptions) BulkWriter {
+	var nOpts = NewBulkWriterOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Size > 0 {
+			nOpts.Size = opt.Size
+		}
+		if opt.Interval > 0 {
+			nOpts.Interval = opt.Interval
+		}
+		if opt.Ordered {
+			nOpts.Ordered = true
+		}
+		if opt.OnFlush != nil {
+			nOpts.OnFlush = opt.OnFlush
+		}
+	}
+	if nOpts.Size <= 0 {
+		nOpts.Size = 1000
+	}
+	if nOpts.Interval <= 0 {
+		nOpts.Interval = time.Second
+	}
+	return &bulkWriter{collection: collection, opts: nOpts}
+}
+
+func (w *bulkWriter) Run(ctx context.Context, models <-chan WriteModel) error {
+	var ticker = time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	var buffer = make([]WriteModel, 0, w.opts.Size)
+	for {
+		select {
+		case <-ctx.Done():
+			if len(buffer) > 0 {
+				return &UnflushedError{Models: buffer, Err: ctx.Err()}
+			}
+			return ctx.Err()
+		case model, ok := <-models:
+			if !ok {
+				return w.flush(ctx, buffer)
+			}
+			if model == nil {
+				continue
+			}
+			if buffer = append(buffer, model); len(buffer) < w.opts.Size {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if err := w.flush(ctx, buffer); err != nil {
+			return err
+		}
+		buffer = make([]WriteModel, 0, w.opts.Size)
+		ticker.Reset(w.opts.Interval)
+	}
+}
+
+func (w *bulkWriter) flush(ctx context.Context, models []WriteModel) error {
+	if len(models) == 0 {
+		return nil
+	}
+
+	var bulk = w.collection.Bulk().Ordered(w.opts.Ordered)
+	for _, model := range models {
+		bulk.AddModel(model)
+	}
+	var result, err = bulk.Apply(ctx)
+	if w.opts.OnFlush != nil {
+		return w.opts.OnFlush(ctx, result, err)
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package dbm_test
+
+import (
+	"context"
+	"errors"
+	"github.com/smartwalle/dbm"
+	"sync"
+	"testing"
+	"time"
+)
+
+// embeddedCollection 是 dbm.Collection 的别名，dbm.Collection 中有 Collection 方法，直接嵌入时字段名称会与该方法冲突
+type embeddedCollection = dbm.Collection
+
+// fakeBulkCollection 记录每次 Bulk.Apply 写入的操作
+type fakeBulkCollection struct {
+	embeddedCollection
+	mu      sync.Mutex
+	batches [][]dbm.WriteModel
+	flushed chan int
+}
+
+func newFakeBulkCollection() *fakeBulkCollection {
+	return &fakeBulkCollection{flushed: make(chan int, 100)}
+}
+
+func (c *fakeBulkCollection) Bulk() dbm.Bulk {
+	return &fakeBulk{collection: c}
+}
+
+func (c *fakeBulkCollection) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sizes = make([]int, 0, len(c.batches))
+	for _, batch := range c.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+type fakeBulk struct {
+	dbm.Bulk
+	collection *fakeBulkCollection
+	models     []dbm.WriteModel
+}
+
+func (b *fakeBulk) Ordered(ordered bool) dbm.Bulk {
+	return b
+}
+
+func (b *fakeBulk) AddModel(m dbm.WriteModel) dbm.Bulk {
+	b.models = append(b.models, m)
+	return b
+}
+
+func (b *fakeBulk) Apply(ctx context.Context) (*dbm.BulkResult, error) {
+	b.collection.mu.Lock()
+	b.collection.batches = append(b.collection.batches, b.models)
+	b.collection.mu.Unlock()
+	b.collection.flushed <- len(b.models)
+	return &dbm.BulkResult{}, nil
+}
+
+func sameSizes(sizes, expected []int) bool {
+	if len(sizes) != len(expected) {
+		return false
+	}
+	for i := range sizes {
+		if sizes[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func runBulkWriter(ctx context.Context, writer dbm.BulkWriter, models <-chan dbm.WriteModel) <-chan error {
+	var done = make(chan error, 1)
+	go func() {
+		done <- writer.Run(ctx, models)
+	}()
+	return done
+}
+
+func TestBulkWriter_Size(t *testing.T) {
+	var collection = newFakeBulkCollection()
+	var writer = dbm.NewBulkWriter(collection, dbm.NewBulkWriterOptions().SetSize(3).SetInterval(time.Hour))
+
+	var models = make(chan dbm.WriteModel)
+	var done = runBulkWriter(context.Background(), writer, models)
+	for i := 0; i < 7; i++ {
+		models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": i})
+	}
+	close(models)
+
+	if err := <-done; err != nil {
+		t.Fatal("写入发生错误", err)
+	}
+	// 达到 Size 时写入，models 被关闭时写入剩余的操作
+	if sizes := collection.sizes(); !sameSizes(sizes, []int{3, 3, 1}) {
+		t.Fatal("写入的批次不匹配", sizes)
+	}
+}
+
+func TestBulkWriter_Interval(t *testing.T) {
+	var collection = newFakeBulkCollection()
+	var writer = dbm.NewBulkWriter(collection, dbm.NewBulkWriterOptions().SetSize(100).SetInterval(20*time.Millisecond))
+
+	var models = make(chan dbm.WriteModel)
+	var done = runBulkWriter(context.Background(), writer, models)
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 1})
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 2})
+
+	select {
+	case n := <-collection.flushed:
+		if n != 2 {
+			t.Fatal("写入的操作数量不匹配", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有达到 Size 时应该在 Interval 之后写入")
+	}
+
+	close(models)
+	if err := <-done; err != nil {
+		t.Fatal("写入发生错误", err)
+	}
+	// models 被关闭时没有剩余的操作，不会再次写入
+	if sizes := collection.sizes(); !sameSizes(sizes, []int{2}) {
+		t.Fatal("写入的批次不匹配", sizes)
+	}
+}
+
+func TestBulkWriter_Close(t *testing.T) {
+	var collection = newFakeBulkCollection()
+	var writer = dbm.NewBulkWriter(collection, dbm.NewBulkWriterOptions().SetSize(100).SetInterval(time.Hour))
+
+	var models = make(chan dbm.WriteModel, 3)
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 1})
+	models <- nil
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 2})
+	close(models)
+
+	if err := writer.Run(context.Background(), models); err != nil {
+		t.Fatal("写入发生错误", err)
+	}
+	if sizes := collection.sizes(); !sameSizes(sizes, []int{2}) {
+		t.Fatal("写入的批次不匹配", sizes)
+	}
+}
+
+func TestBulkWriter_Cancel(t *testing.T) {
+	var collection = newFakeBulkCollection()
+	var writer = dbm.NewBulkWriter(collection, dbm.NewBulkWriterOptions().SetSize(100).SetInterval(time.Hour))
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var models = make(chan dbm.WriteModel)
+	var done = runBulkWriter(ctx, writer, models)
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 1})
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 2})
+	cancel()
+
+	var err = <-done
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("ctx 被取消时应该返回 context.Canceled", err)
+	}
+	// 已经读取但是没有写入的操作通过 UnflushedError 返回
+	var uErr *dbm.UnflushedError
+	if !errors.As(err, &uErr) || len(uErr.Models) != 2 {
+		t.Fatal("应该返回没有写入的操作", err)
+	}
+	if sizes := collection.sizes(); len(sizes) != 0 {
+		t.Fatal("ctx 被取消之后不应该写入", sizes)
+	}
+
+	// 没有剩余的操作时直接返回 ctx 的错误
+	done = runBulkWriter(ctx, writer, make(chan dbm.WriteModel))
+	if err = <-done; err != context.Canceled {
+		t.Fatal("ctx 被取消时应该返回 context.Canceled", err)
+	}
+}
+
+func TestBulkWriter_OnFlush(t *testing.T) {
+	var collection = newFakeBulkCollection()
+	var errStop = errors.New("stop")
+	var opts = dbm.NewBulkWriterOptions().SetSize(1).SetInterval(time.Hour)
+	opts.SetOnFlush(func(ctx context.Context, result *dbm.BulkResult, err error) error {
+		return errStop
+	})
+	var writer = dbm.NewBulkWriter(collection, opts)
+
+	var models = make(chan dbm.WriteModel, 2)
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 1})
+	models <- dbm.NewInsertOneModel().SetDocument(dbm.M{"_id": 2})
+
+	if err := writer.Run(context.Background(), models); !errors.Is(err, errStop) {
+		t.Fatal("OnFlush 返回错误时应该停止运行", err)
+	}
+	if sizes := collection.sizes(); !sameSizes(sizes, []int{1}) {
+		t.Fatal("写入的批次不匹配", sizes)
+	}
+}
@@ -40,6 +40,18 @@ type Client interface {
 
 	BeginTx(ctx context.Context, opts ...*TransactionOptions) (Tx, error)
 
+	// BeginTxWith 根据 propagation 加入 ctx 中已经存在的事务或者开启新的事务。
+	//
+	// 加入已经存在的事务时，返回的 Tx 的 Commit 不会提交事务，由外层事务负责提交。
+	BeginTxWith(ctx context.Context, propagation Propagation, opts ...*TransactionOptions) (Tx, error)
+
+	// WithTx 在事务中执行 fn，fn 返回 nil 时提交事务，返回错误或者 panic 时回滚事务；fn 中不需要调用 Commit 和 Rollback。
+	//
+	// 发生 TransientTransactionError 时会重新执行 fn，所以 fn 需要可以被重复执行。
+	//
+	// ctx 中已经存在事务时，默认加入该事务，可以通过 TxOptions.SetPropagation 修改。
+	WithTx(ctx context.Context, fn func(tx Tx) error, opts ...*TxOptions) error
+
 	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error)
 }
 
@@ -172,7 +184,7 @@ func (c *client) TransactionAllowed() bool {
 }
 
 func (c *client) Database(name string, opts ...*DatabaseOptions) Database {
-	return &database{database: c.client.Database(name, opts...), client: c}
+	return &database{database: c.client.Database(name, opts...), client: c, interceptors: c.config.Interceptors}
 }
 
 func (c *client) UseSession(ctx context.Context, fn func(SessionContext) error) error {
@@ -205,6 +217,10 @@ func (c *client) StartSession(opts ...*SessionOptions) (Session, error) {
 }
 
 func (c *client) BeginTx(ctx context.Context, opts ...*TransactionOptions) (Tx, error) {
+	if c.fallbackTx(ctx) {
+		return newNoopTx(ctx), nil
+	}
+
 	var sess, err = c.startSession()
 	if err != nil {
 		return nil, err
@@ -214,11 +230,51 @@ func (c *client) BeginTx(ctx context.Context, opts ...*TransactionOptions) (Tx,
 		sess.EndSession(ctx)
 		return nil, err
 	}
-	return &transaction{mongo.NewSessionContext(ctx, sess), true}, nil
+	return newTransaction(ctx, sess, true), nil
+}
+
+func (c *client) BeginTxWith(ctx context.Context, propagation Propagation, opts ...*TransactionOptions) (Tx, error) {
+	if tx := joinTx(ctx, propagation); tx != nil {
+		return tx, nil
+	}
+	return c.BeginTx(ctx, opts...)
+}
+
+func (c *client) WithTx(ctx context.Context, fn func(tx Tx) error, opts ...*TxOptions) error {
+	var nOpts = mergeTxOptions(opts...)
+	if tx := joinTx(ctx, nOpts.Propagation); tx != nil {
+		return runLocalTx(ctx, tx, fn)
+	}
+	if c.fallbackTx(ctx) {
+		return runLocalTx(ctx, newNoopTx(ctx), fn)
+	}
+
+	var sess, err = c.startSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(context.Background())
+	return withTx(ctx, sess, fn, nOpts)
+}
+
+// fallbackTx 返回是否需要使用不会真正开启事务的 Tx
+func (c *client) fallbackTx(ctx context.Context) bool {
+	if c.transactionAllowed || !c.config.FallbackTx {
+		return false
+	}
+	if c.config.OnFallbackTx != nil {
+		c.config.OnFallbackTx(ctx)
+	}
+	return true
 }
 
-func (c *client) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
-	return c.client.Watch(ctx, pipeline, opts...)
+func (c *client) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (stream *ChangeStream, err error) {
+	var op = &Operation{Name: OpWatch, Pipeline: pipeline}
+	err = invoke(ctx, c.config.Interceptors, op, func(ctx context.Context, op *Operation) (err error) {
+		stream, err = c.client.Watch(ctx, op.Pipeline, opts...)
+		return err
+	})
+	return stream, err
 }
 
 func CompareServerVersions(v1 string, v2 string) int {
@@ -2,6 +2,7 @@ package dbm
 
 import (
 	"context"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -54,6 +55,9 @@ type Collection interface {
 
 	Clone(opts ...*CollectionOptions) (Collection, error)
 
+	WithInterceptor(interceptors ...Interceptor) Collection
+
+	// IndexView 返回的 IndexView 的操作不会经过拦截器
 	IndexView() IndexView
 
 	InsertOne(ctx context.Context, document interface{}, opts ...*InsertOneOptions) (*InsertOneResult, error)
@@ -100,12 +104,19 @@ type Collection interface {
 
 	Aggregate(ctx context.Context, pipeline interface{}) Aggregate
 
+	// ParallelScan 按照 _id 区间并发处理满足 filter 的文档，返回每个区间的处理进度
+	ParallelScan(ctx context.Context, filter interface{}, workers int, fn ScanHandler, opts ...*ParallelScanOptions) ([]*ScanRange, error)
+
+	// ResumeScan 继续处理 ParallelScan 返回的区间，用于重试处理失败的区间
+	ResumeScan(ctx context.Context, filter interface{}, r *ScanRange, fn ScanHandler, opts ...*ParallelScanOptions) error
+
 	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error)
 }
 
 type collection struct {
-	collection *mongo.Collection
-	database   Database
+	collection   *mongo.Collection
+	database     Database
+	interceptors []Interceptor
 }
 
 func (c *collection) Database() Database {
@@ -121,7 +132,10 @@ func (c *collection) Name() string {
 }
 
 func (c *collection) Drop(ctx context.Context) error {
-	return c.collection.Drop(ctx)
+	var op = &Operation{Name: OpDrop}
+	return c.invoke(ctx, op, func(ctx context.Context, op *Operation) error {
+		return c.collection.Drop(ctx)
+	})
 }
 
 func (c *collection) Clone(opts ...*CollectionOptions) (Collection, error) {
@@ -129,7 +143,18 @@ func (c *collection) Clone(opts ...*CollectionOptions) (Collection, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &collection{collection: nCollection, database: c.database}, nil
+	return &collection{collection: nCollection, database: c.database, interceptors: c.interceptors}, nil
+}
+
+// WithInterceptor 返回一个新的 Collection，其拦截器为当前 Collection 的拦截器加上 interceptors。
+func (c *collection) WithInterceptor(interceptors ...Interceptor) Collection {
+	return &collection{collection: c.collection, database: c.database, interceptors: appendInterceptors(c.interceptors, interceptors...)}
+}
+
+func (c *collection) invoke(ctx context.Context, op *Operation, handler Handler) error {
+	op.Database = c.collection.Database().Name()
+	op.Collection = c.collection.Name()
+	return invoke(ctx, c.interceptors, op, handler)
 }
 
 func (c *collection) IndexView() IndexView {
@@ -137,76 +162,128 @@ func (c *collection) IndexView() IndexView {
 	return &indexView{view: view}
 }
 
-func (c *collection) InsertOne(ctx context.Context, document interface{}, opts ...*InsertOneOptions) (*InsertOneResult, error) {
-	return c.collection.InsertOne(ctx, document, opts...)
+func (c *collection) InsertOne(ctx context.Context, document interface{}, opts ...*InsertOneOptions) (result *InsertOneResult, err error) {
+	var op = &Operation{Name: OpInsertOne, Document: document}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.InsertOne(ctx, op.Document, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) InsertOneNx(ctx context.Context, filter interface{}, document interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
 	var opt = options.MergeUpdateOptions(opts...)
 	opt.SetUpsert(true)
 	// mongodb update 操作中，当 upsert 为 true 时，如果满足查询条件的记录存在，不会执行 $setOnInsert 中的操作
-	return c.collection.UpdateOne(ctx, filter, bson.D{{"$setOnInsert", document}}, opt)
+	return c.updateOne(ctx, filter, bson.D{{"$setOnInsert", document}}, opt)
 }
 
-func (c *collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*InsertManyOptions) (*InsertManyResult, error) {
-	return c.collection.InsertMany(ctx, documents, opts...)
+func (c *collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*InsertManyOptions) (result *InsertManyResult, err error) {
+	var op = &Operation{Name: OpInsertMany, Document: documents}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		var nDocuments, ok = op.Document.([]interface{})
+		if !ok {
+			return fmt.Errorf("dbm: insertMany documents must be []interface{}, got %T", op.Document)
+		}
+		result, err = c.collection.InsertMany(ctx, nDocuments, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) Insert(ctx context.Context, documents ...interface{}) (*InsertManyResult, error) {
 	var opts = options.InsertMany()
-	return c.collection.InsertMany(ctx, documents, opts)
+	return c.InsertMany(ctx, documents, opts)
 }
 
 func (c *collection) RepsertOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*ReplaceOptions) (*UpdateResult, error) {
 	var opt = options.MergeReplaceOptions(opts...)
 	opt.SetUpsert(true)
-	return c.collection.ReplaceOne(ctx, filter, replacement, opt)
+	return c.ReplaceOne(ctx, filter, replacement, opt)
 }
 
-func (c *collection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*ReplaceOptions) (*UpdateResult, error) {
-	return c.collection.ReplaceOne(ctx, filter, replacement, opts...)
+func (c *collection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*ReplaceOptions) (result *UpdateResult, err error) {
+	var op = &Operation{Name: OpReplaceOne, Filter: filter, Update: replacement}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.ReplaceOne(ctx, op.Filter, op.Update, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) UpsertOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
 	var opt = options.MergeUpdateOptions(opts...)
 	opt.SetUpsert(true)
-	return c.collection.UpdateOne(ctx, filter, update, opt)
+	return c.updateOne(ctx, filter, update, opt)
 }
 
 func (c *collection) UpsertId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
 	var opt = options.MergeUpdateOptions(opts...)
 	opt.SetUpsert(true)
-	return c.collection.UpdateOne(ctx, bson.D{{"_id", id}}, update, opt)
+	return c.updateOne(ctx, bson.D{{"_id", id}}, update, opt)
 }
 
 func (c *collection) Upsert(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
 	var opt = options.MergeUpdateOptions(opts...)
 	opt.SetUpsert(true)
-	return c.collection.UpdateMany(ctx, filter, update, opt)
+	return c.updateMany(ctx, filter, update, opt)
 }
 
 func (c *collection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
-	return c.collection.UpdateOne(ctx, filter, update, opts...)
+	return c.updateOne(ctx, filter, update, opts...)
 }
 
-func (c *collection) UpdateId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
-	return c.collection.UpdateByID(ctx, id, update, opts...)
+func (c *collection) UpdateId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (result *UpdateResult, err error) {
+	var op = &Operation{Name: OpUpdateById, Id: id, Update: update}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.UpdateByID(ctx, op.Id, op.Update, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
-	return c.collection.UpdateMany(ctx, filter, update, opts...)
+	return c.updateMany(ctx, filter, update, opts...)
+}
+
+func (c *collection) updateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (result *UpdateResult, err error) {
+	var op = &Operation{Name: OpUpdateOne, Filter: filter, Update: update}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.UpdateOne(ctx, op.Filter, op.Update, opts...)
+		return err
+	})
+	return result, err
+}
+
+func (c *collection) updateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (result *UpdateResult, err error) {
+	var op = &Operation{Name: OpUpdateMany, Filter: filter, Update: update}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.UpdateMany(ctx, op.Filter, op.Update, opts...)
+		return err
+	})
+	return result, err
 }
 
-func (c *collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
-	return c.collection.DeleteOne(ctx, filter, opts...)
+func (c *collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (result *DeleteResult, err error) {
+	var op = &Operation{Name: OpDeleteOne, Filter: filter}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.DeleteOne(ctx, op.Filter, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) DeleteId(ctx context.Context, id interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
-	return c.collection.DeleteOne(ctx, bson.D{{"_id", id}}, opts...)
+	return c.DeleteOne(ctx, bson.D{{"_id", id}}, opts...)
 }
 
-func (c *collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
-	return c.collection.DeleteMany(ctx, filter, opts...)
+func (c *collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (result *DeleteResult, err error) {
+	var op = &Operation{Name: OpDeleteMany, Filter: filter}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		result, err = c.collection.DeleteMany(ctx, op.Filter, opts...)
+		return err
+	})
+	return result, err
 }
 
 func (c *collection) Find(ctx context.Context, filter interface{}) Query {
@@ -269,9 +346,15 @@ func (c *collection) Aggregate(ctx context.Context, pipeline interface{}) Aggreg
 	a.ctx = ctx
 	a.opts = options.Aggregate()
 	a.aggregator = c.collection
+	a.invoker = c
 	return a
 }
 
-func (c *collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
-	return c.collection.Watch(ctx, pipeline, opts...)
+func (c *collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (stream *ChangeStream, err error) {
+	var op = &Operation{Name: OpWatch, Pipeline: pipeline}
+	err = c.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		stream, err = c.collection.Watch(ctx, op.Pipeline, opts...)
+		return err
+	})
+	return stream, err
 }
@@ -1 +1,209 @@
 package dbm_test
+
+import (
+	"context"
+	"errors"
+	"github.com/smartwalle/dbm"
+	"sync"
+	"testing"
+)
+
+func TestCollection_WithInterceptor(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+
+	var names []string
+	var tUser = db.Collection("user").WithInterceptor(func(ctx context.Context, op *dbm.Operation, next dbm.Handler) error {
+		names = append(names, op.Name)
+		if op.Collection != "user" {
+			t.Fatal("命名空间不匹配", op.Database, op.Collection)
+		}
+		return next(ctx, op)
+	})
+
+	var uid = dbm.NewObjectId().Hex()
+	if _, err := tUser.InsertOne(context.Background(), &User{Id: uid, Age: 10, Name: "Interceptor"}); err != nil {
+		t.Fatal("插入数据发生错误", err)
+	}
+
+	var user *User
+	if err := tUser.Find(context.Background(), dbm.M{"_id": uid}).One(&user); err != nil {
+		t.Fatal("查询数据发生错误", err)
+	}
+
+	if _, err := tUser.DeleteId(context.Background(), uid); err != nil {
+		t.Fatal("删除数据发生错误", err)
+	}
+
+	if len(names) != 3 || names[0] != dbm.OpInsertOne || names[1] != dbm.OpFindOne || names[2] != dbm.OpDeleteOne {
+		t.Fatal("拦截器调用记录不匹配", names)
+	}
+}
+
+func TestCollection_ParallelScan(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var name = "ParallelScan-" + dbm.NewObjectId().Hex()
+	var users = make([]interface{}, 0, 100)
+	for i := 0; i < 100; i++ {
+		users = append(users, &User{Id: dbm.NewObjectId().Hex(), Age: i, Name: name})
+	}
+	if _, err := tUser.InsertMany(context.Background(), users); err != nil {
+		t.Fatal("插入数据发生错误", err)
+	}
+	defer tUser.DeleteMany(context.Background(), dbm.M{"name": name})
+
+	var mu sync.Mutex
+	var seen = make(map[string]bool)
+	var ranges, err = tUser.ParallelScan(context.Background(), dbm.M{"name": name}, 4, func(ctx context.Context, cur dbm.Cursor) error {
+		var user *User
+		if err := cur.One(&user); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[user.Id] {
+			t.Fatal("区间之间不应该有重复的数据", user.Id)
+		}
+		seen[user.Id] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal("扫描数据发生错误", err)
+	}
+
+	var total int64
+	for _, r := range ranges {
+		if !r.Done {
+			t.Fatal("区间没有处理完成", r.Index)
+		}
+		total += r.Count
+	}
+	if total != 100 || len(seen) != 100 {
+		t.Fatal("扫描结果不匹配", total, len(seen))
+	}
+}
+
+func TestIndexView_Exists(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+
+	var view = db.Collection("index_exists_" + dbm.NewObjectId().Hex()).IndexView()
+	defer view.DropAll(context.Background())
+
+	// 使用默认索引名称 age_1 的 key 不一致的索引
+	if _, err := view.CreateIndex(context.Background(), "age_1", []string{"name"}); err != nil {
+		t.Fatal("创建索引发生错误", err)
+	}
+	if _, err := view.CreateIndex(context.Background(), "idx_custom", []string{"+name", "-age"}); err != nil {
+		t.Fatal("创建索引发生错误", err)
+	}
+
+	var tests = []struct {
+		keys     []string
+		expected bool
+	}{
+		{keys: []string{"name"}, expected: true},
+		{keys: []string{"name", "-age"}, expected: true},
+		{keys: []string{"age"}, expected: false},
+		{keys: []string{"name", "age"}, expected: false},
+	}
+	for _, test := range tests {
+		var exists, err = view.Exists(context.Background(), test.keys)
+		if err != nil {
+			t.Fatal("查询索引发生错误", err)
+		}
+		if exists != test.expected {
+			t.Fatal("索引匹配结果不一致", test.keys, exists)
+		}
+	}
+}
+
+func TestQuery_Paginate(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var name = "Paginate-" + dbm.NewObjectId().Hex()
+	var users = make([]interface{}, 0, 25)
+	for i := 0; i < 25; i++ {
+		// age 存在重复的值，需要通过 _id 区分
+		users = append(users, &User{Id: dbm.NewObjectId().Hex(), Age: i % 5, Name: name})
+	}
+	if _, err := tUser.InsertMany(context.Background(), users); err != nil {
+		t.Fatal("插入数据发生错误", err)
+	}
+	defer tUser.DeleteMany(context.Background(), dbm.M{"name": name})
+
+	var seen = make(map[string]bool)
+	var last *User
+	var token string
+	for {
+		var page []*User
+		var err error
+		token, err = tUser.Find(context.Background(), dbm.M{"name": name}).Sort("-age").Paginate(token, 7, &page)
+		if err != nil {
+			t.Fatal("分页查询发生错误", err)
+		}
+		for _, user := range page {
+			if seen[user.Id] {
+				t.Fatal("分页之间不应该有重复的数据", user.Id)
+			}
+			seen[user.Id] = true
+			if last != nil && (user.Age > last.Age || (user.Age == last.Age && user.Id < last.Id)) {
+				t.Fatal("分页数据的顺序不正确", last, user)
+			}
+			last = user
+		}
+		if token == "" {
+			break
+		}
+	}
+	if len(seen) != 25 {
+		t.Fatal("分页数据数量不匹配", len(seen))
+	}
+
+	var page []*User
+	if _, err := tUser.Find(context.Background(), dbm.M{"name": name}).Sort("age").Paginate("invalid", 7, &page); !errors.Is(err, dbm.ErrInvalidPageToken) {
+		t.Fatal("无效的 token 应该返回 ErrInvalidPageToken", err)
+	}
+}
+
+func TestCollection_ParallelScanMixedId(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tScan = db.Collection("scan_" + dbm.NewObjectId().Hex())
+	defer tScan.Drop(context.Background())
+
+	var docs = make([]interface{}, 0, 103)
+	for i := 0; i < 100; i++ {
+		docs = append(docs, dbm.M{"_id": dbm.NewObjectId().Hex()})
+	}
+	// 采样时很难发现的其它类型的 _id
+	docs = append(docs, dbm.M{"_id": 1}, dbm.M{"_id": dbm.NewObjectId()}, dbm.M{"_id": 2.5})
+	if _, err := tScan.InsertMany(context.Background(), docs); err != nil {
+		t.Fatal("插入数据发生错误", err)
+	}
+
+	var mu sync.Mutex
+	var count int
+	var ranges, err = tScan.ParallelScan(context.Background(), nil, 4, func(ctx context.Context, cur dbm.Cursor) error {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		return nil
+	}, dbm.NewParallelScanOptions().SetSampleSize(20))
+	if err != nil {
+		t.Fatal("扫描数据发生错误", err)
+	}
+
+	var total int64
+	for _, r := range ranges {
+		total += r.Count
+	}
+	if total != 103 || count != 103 {
+		t.Fatal("扫描结果不匹配", total, count)
+	}
+}
@@ -1,11 +1,22 @@
 package dbm
 
 import (
+	"context"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Config struct {
 	*options.ClientOptions
+
+	// Interceptors 会作用于由该 Client 创建的所有 Database 和 Collection
+	Interceptors []Interceptor
+
+	// FallbackTx 为 true 时，在不支持事务的服务器（如单节点）上 BeginTx 和 WithTx 会返回不会真正开启事务的 Tx，
+	// 其中的操作会直接执行，Commit 和 Rollback 总是成功
+	FallbackTx bool
+
+	// OnFallbackTx 在使用不会真正开启事务的 Tx 时调用，可以用于输出警告
+	OnFallbackTx func(ctx context.Context)
 }
 
 func NewConfig(uri string) *Config {
@@ -14,3 +25,18 @@ func NewConfig(uri string) *Config {
 	cfg.ClientOptions.ApplyURI(uri)
 	return cfg
 }
+
+func (cfg *Config) AddInterceptor(interceptors ...Interceptor) *Config {
+	cfg.Interceptors = appendInterceptors(cfg.Interceptors, interceptors...)
+	return cfg
+}
+
+func (cfg *Config) SetFallbackTx(fallback bool) *Config {
+	cfg.FallbackTx = fallback
+	return cfg
+}
+
+func (cfg *Config) SetOnFallbackTx(fn func(ctx context.Context)) *Config {
+	cfg.OnFallbackTx = fn
+	return cfg
+}
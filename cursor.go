@@ -3,6 +3,7 @@ package dbm
 import (
 	"context"
 	"go.mongodb.org/mongo-driver/mongo"
+	"reflect"
 )
 
 type Cursor interface {
@@ -16,6 +17,11 @@ type Cursor interface {
 
 	All(ctx context.Context, result interface{}) error
 
+	// NextBatch 读取最多 n 个文档到 result 中，result 需要为 slice 的指针，其底层数组会被复用，没有更多文档时 result 的长度为 0。
+	//
+	// 当前批次的文档读取完之后会直接返回，不会为了凑满 n 个文档而发起新的 getMore 请求。
+	NextBatch(ctx context.Context, n int, result interface{}) error
+
 	RemainingBatchLength() int
 
 	Close(ctx context.Context) error
@@ -28,6 +34,11 @@ type cursor struct {
 	err error
 }
 
+// NewCursor 将 mongo.Cursor 包装为 Cursor
+func NewCursor(cur *mongo.Cursor) Cursor {
+	return &cursor{Cursor: cur}
+}
+
 func (c *cursor) ID() int64 {
 	if c.err != nil {
 		return 0
@@ -63,6 +74,13 @@ func (c *cursor) All(ctx context.Context, result interface{}) error {
 	return c.Cursor.All(ctx, result)
 }
 
+func (c *cursor) NextBatch(ctx context.Context, n int, result interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	return nextBatch(ctx, c, n, result)
+}
+
 func (c *cursor) RemainingBatchLength() int {
 	if c.err != nil {
 		return 0
@@ -70,6 +88,51 @@ func (c *cursor) RemainingBatchLength() int {
 	return c.Cursor.RemainingBatchLength()
 }
 
+func nextBatch(ctx context.Context, cur Cursor, n int, result interface{}) error {
+	if n <= 0 {
+		return ErrInvalidBatchSize
+	}
+
+	var resultValue = reflect.ValueOf(result)
+	if resultValue.Kind() != reflect.Ptr || resultValue.Elem().Kind() != reflect.Slice {
+		return ErrResultNotSlice
+	}
+
+	var sliceValue = resultValue.Elem()
+	var elemType = sliceValue.Type().Elem()
+	var batch = sliceValue.Slice(0, 0)
+	defer func() {
+		sliceValue.Set(batch)
+	}()
+
+	for batch.Len() < n {
+		// 当前批次已经读取完，直接返回已经读取到的文档
+		if batch.Len() > 0 && cur.RemainingBatchLength() == 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !cur.Next(ctx) {
+			break
+		}
+
+		if batch.Len() < batch.Cap() {
+			batch = batch.Slice(0, batch.Len()+1)
+		} else {
+			batch = reflect.Append(batch, reflect.Zero(elemType))
+		}
+		// 复用的元素中可能有上一次解析的数据
+		var elem = batch.Index(batch.Len() - 1)
+		elem.Set(reflect.Zero(elemType))
+		if err := cur.One(elem.Addr().Interface()); err != nil {
+			batch = batch.Slice(0, batch.Len()-1)
+			return err
+		}
+	}
+	return cur.Error()
+}
+
 func (c *cursor) Close(ctx context.Context) error {
 	if c.err != nil {
 		return c.err
@@ -83,3 +146,149 @@ func (c *cursor) Error() error {
 	}
 	return c.Cursor.Err()
 }
+
+// CursorOf 在 Cursor 的基础上将文档解析为 T。
+type CursorOf[T any] interface {
+	Cursor() Cursor
+
+	Next(ctx context.Context) bool
+
+	// One 解析当前文档
+	One() (T, error)
+
+	// NextBatch 读取最多 n 个文档，复用 buffer 的底层数组，没有更多文档时返回的 slice 长度为 0
+	NextBatch(ctx context.Context, n int, buffer []T) ([]T, error)
+
+	// Each 依次处理所有文档，fn 返回错误时停止，处理完成之后总是会关闭 Cursor
+	Each(ctx context.Context, fn func(T) error) error
+
+	// EachBatch 每次读取最多 n 个文档并调用 fn，batch 的底层数组会被复用，fn 中不能保留 batch；
+	// fn 返回错误或者 ctx 被取消时停止并返回该错误，处理完成之后总是会关闭 Cursor
+	EachBatch(ctx context.Context, n int, fn func(batch []T) error) error
+
+	// Chan 在新的 goroutine 中读取所有文档并发送到返回的 channel 中，buffer 为 channel 的缓冲大小。
+	//
+	// 读取完成之后关闭 Cursor 和这两个 channel，发生错误时（包括 ctx 被取消）错误会在 value channel 关闭之前发送到 error channel 中。
+	Chan(ctx context.Context, buffer int) (<-chan T, <-chan error)
+
+	// All 返回与 iter.Seq2[T, error] 兼容的迭代器，可以在 Go 1.23 及以上版本中使用 for range 遍历，遍历结束之后总是会关闭 Cursor：
+	//
+	//	for user, err := range cur.All(ctx) {
+	//	}
+	All(ctx context.Context) func(yield func(T, error) bool)
+
+	Close(ctx context.Context) error
+
+	Error() error
+}
+
+type cursorOf[T any] struct {
+	cursor Cursor
+}
+
+func NewCursorOf[T any](cur Cursor) CursorOf[T] {
+	return &cursorOf[T]{cursor: cur}
+}
+
+func (c *cursorOf[T]) Cursor() Cursor {
+	return c.cursor
+}
+
+func (c *cursorOf[T]) Next(ctx context.Context) bool {
+	return c.cursor.Next(ctx)
+}
+
+func (c *cursorOf[T]) One() (T, error) {
+	var value T
+	var err = c.cursor.One(&value)
+	return value, err
+}
+
+func (c *cursorOf[T]) NextBatch(ctx context.Context, n int, buffer []T) ([]T, error) {
+	var err = c.cursor.NextBatch(ctx, n, &buffer)
+	return buffer, err
+}
+
+func (c *cursorOf[T]) Each(ctx context.Context, fn func(T) error) error {
+	defer c.cursor.Close(context.Background())
+
+	for c.cursor.Next(ctx) {
+		var value, err = c.One()
+		if err != nil {
+			return err
+		}
+		if err = fn(value); err != nil {
+			return err
+		}
+	}
+	return c.cursor.Error()
+}
+
+func (c *cursorOf[T]) EachBatch(ctx context.Context, n int, fn func(batch []T) error) error {
+	defer c.cursor.Close(context.Background())
+
+	var batch []T
+	for {
+		var err error
+		if batch, err = c.NextBatch(ctx, n, batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err = fn(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *cursorOf[T]) Chan(ctx context.Context, buffer int) (<-chan T, <-chan error) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	var values = make(chan T, buffer)
+	var errs = make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(values)
+
+		var err = c.Each(ctx, func(value T) error {
+			select {
+			case values <- value:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return values, errs
+}
+
+func (c *cursorOf[T]) All(ctx context.Context) func(yield func(T, error) bool) {
+	return func(yield func(T, error) bool) {
+		defer c.cursor.Close(context.Background())
+
+		for c.cursor.Next(ctx) {
+			var value, err = c.One()
+			if !yield(value, err) || err != nil {
+				return
+			}
+		}
+		if err := c.cursor.Error(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+func (c *cursorOf[T]) Close(ctx context.Context) error {
+	return c.cursor.Close(ctx)
+}
+
+func (c *cursorOf[T]) Error() error {
+	return c.cursor.Error()
+}
@@ -0,0 +1,132 @@
+package dbm_test
+
+import (
+	"context"
+	"errors"
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func newTestCursor(t *testing.T, n int) dbm.Cursor {
+	var docs = make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, &User{Id: dbm.NewObjectId().Hex(), Age: i})
+	}
+	var cur, err = mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatal("创建 Cursor 发生错误", err)
+	}
+	return dbm.NewCursor(cur)
+}
+
+func TestCursorOf_Each(t *testing.T) {
+	var ages []int
+	var err = dbm.NewCursorOf[*User](newTestCursor(t, 3)).Each(context.Background(), func(user *User) error {
+		ages = append(ages, user.Age)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("遍历 Cursor 发生错误", err)
+	}
+	if len(ages) != 3 || ages[0] != 0 || ages[2] != 2 {
+		t.Fatal("遍历结果不匹配", ages)
+	}
+}
+
+func TestCursorOf_Chan(t *testing.T) {
+	var values, errs = dbm.NewCursorOf[User](newTestCursor(t, 5)).Chan(context.Background(), 1)
+
+	var count int
+	for range values {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Fatal("遍历 Cursor 发生错误", err)
+	}
+	if count != 5 {
+		t.Fatal("遍历结果不匹配", count)
+	}
+}
+
+func TestCursorOf_All(t *testing.T) {
+	var count int
+	dbm.NewCursorOf[User](newTestCursor(t, 5)).All(context.Background())(func(user User, err error) bool {
+		if err != nil {
+			t.Fatal("遍历 Cursor 发生错误", err)
+		}
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Fatal("提前结束遍历之后不应该再继续", count)
+	}
+}
+
+func TestCursor_NextBatch(t *testing.T) {
+	var cur = newTestCursor(t, 5)
+	defer cur.Close(context.Background())
+
+	var users []User
+	var sizes []int
+	for {
+		if err := cur.NextBatch(context.Background(), 2, &users); err != nil {
+			t.Fatal("读取数据发生错误", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		sizes = append(sizes, len(users))
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[2] != 1 {
+		t.Fatal("读取结果不匹配", sizes)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := newTestCursor(t, 5).NextBatch(ctx, 2, &users); err == nil {
+		t.Fatal("ctx 被取消之后应该返回错误")
+	}
+}
+
+func TestCursorOf_EachBatch(t *testing.T) {
+	var sizes []int
+	var ages []int
+	var err = dbm.NewCursorOf[User](newTestCursor(t, 5)).EachBatch(context.Background(), 2, func(batch []User) error {
+		sizes = append(sizes, len(batch))
+		for _, user := range batch {
+			ages = append(ages, user.Age)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("读取数据发生错误", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[2] != 1 || len(ages) != 5 || ages[4] != 4 {
+		t.Fatal("读取结果不匹配", sizes, ages)
+	}
+
+	// 未解析的文档
+	var ids = make(map[string]bool)
+	err = dbm.NewCursorOf[bson.Raw](newTestCursor(t, 5)).EachBatch(context.Background(), 3, func(batch []bson.Raw) error {
+		for _, raw := range batch {
+			ids[raw.Lookup("_id").StringValue()] = true
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 5 {
+		t.Fatal("读取结果不匹配", err, len(ids))
+	}
+
+	// fn 返回错误时停止
+	var errStop = errors.New("stop")
+	var calls int
+	err = dbm.NewCursorOf[User](newTestCursor(t, 5)).EachBatch(context.Background(), 2, func(batch []User) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Fatal("fn 返回错误时应该停止", err, calls)
+	}
+}
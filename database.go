@@ -23,6 +23,8 @@ type Database interface {
 
 	Collection(name string, opts ...*CollectionOptions) Collection
 
+	WithInterceptor(interceptors ...Interceptor) Database
+
 	UseSession(ctx context.Context, fn func(SessionContext) error) error
 
 	UseSessionWithOptions(ctx context.Context, opts *options.SessionOptions, fn func(SessionContext) error) error
@@ -31,12 +33,17 @@ type Database interface {
 
 	BeginTx(ctx context.Context, opts ...*TransactionOptions) (Tx, error)
 
+	BeginTxWith(ctx context.Context, propagation Propagation, opts ...*TransactionOptions) (Tx, error)
+
+	WithTx(ctx context.Context, fn func(tx Tx) error, opts ...*TxOptions) error
+
 	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error)
 }
 
 type database struct {
-	database *mongo.Database
-	client   Client
+	database     *mongo.Database
+	client       Client
+	interceptors []Interceptor
 }
 
 func (db *database) Client() Client {
@@ -52,11 +59,24 @@ func (db *database) Name() string {
 }
 
 func (db *database) Drop(ctx context.Context) error {
-	return db.database.Drop(ctx)
+	var op = &Operation{Name: OpDrop}
+	return db.invoke(ctx, op, func(ctx context.Context, op *Operation) error {
+		return db.database.Drop(ctx)
+	})
 }
 
 func (db *database) Collection(name string, opts ...*CollectionOptions) Collection {
-	return &collection{collection: db.database.Collection(name, opts...), database: db}
+	return &collection{collection: db.database.Collection(name, opts...), database: db, interceptors: db.interceptors}
+}
+
+// WithInterceptor 返回一个新的 Database，其拦截器为当前 Database 的拦截器加上 interceptors。
+func (db *database) WithInterceptor(interceptors ...Interceptor) Database {
+	return &database{database: db.database, client: db.client, interceptors: appendInterceptors(db.interceptors, interceptors...)}
+}
+
+func (db *database) invoke(ctx context.Context, op *Operation, handler Handler) error {
+	op.Database = db.database.Name()
+	return invoke(ctx, db.interceptors, op, handler)
 }
 
 func (db *database) Aggregate(ctx context.Context, pipeline interface{}) Aggregate {
@@ -65,6 +85,7 @@ func (db *database) Aggregate(ctx context.Context, pipeline interface{}) Aggrega
 	a.ctx = ctx
 	a.opts = options.Aggregate()
 	a.aggregator = db.database
+	a.invoker = db
 	return a
 }
 
@@ -84,6 +105,19 @@ func (db *database) BeginTx(ctx context.Context, opts ...*TransactionOptions) (T
 	return db.client.BeginTx(ctx, opts...)
 }
 
-func (db *database) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
-	return db.database.Watch(ctx, pipeline, opts...)
+func (db *database) BeginTxWith(ctx context.Context, propagation Propagation, opts ...*TransactionOptions) (Tx, error) {
+	return db.client.BeginTxWith(ctx, propagation, opts...)
+}
+
+func (db *database) WithTx(ctx context.Context, fn func(tx Tx) error, opts ...*TxOptions) error {
+	return db.client.WithTx(ctx, fn, opts...)
+}
+
+func (db *database) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (stream *ChangeStream, err error) {
+	var op = &Operation{Name: OpWatch, Pipeline: pipeline}
+	err = db.invoke(ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		stream, err = db.database.Watch(ctx, op.Pipeline, opts...)
+		return err
+	})
+	return stream, err
 }
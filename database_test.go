@@ -2,6 +2,7 @@ package dbm_test
 
 import (
 	"context"
+	"errors"
 	"github.com/smartwalle/dbm"
 	"testing"
 )
@@ -265,3 +266,116 @@ func TestDatabase_EndSession(t *testing.T) {
 		t.Fatal("Session 已经关闭，这里应该报错")
 	}
 }
+
+func TestDatabase_WithTxCommit(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var uid = dbm.NewObjectId().Hex()
+	var err = db.WithTx(context.Background(), func(tx dbm.Tx) error {
+		_, err := tUser.InsertOne(tx, &User{Id: uid, Age: 10, Name: "WithTxCommit-Good"})
+		return err
+	})
+	if err != nil {
+		t.Fatal("执行事务发生错误", err)
+	}
+
+	var nUser *User
+	if err = tUser.Find(context.Background(), dbm.M{"_id": uid}).One(&nUser); err != nil {
+		t.Fatal("查询数据发生错误", err)
+	}
+}
+
+func TestDatabase_WithTxRollback(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var uid = dbm.NewObjectId().Hex()
+	var bad = errors.New("bad")
+	var err = db.WithTx(context.Background(), func(tx dbm.Tx) error {
+		if _, err := tUser.InsertOne(tx, &User{Id: uid, Age: 10, Name: "WithTxRollback-Bad"}); err != nil {
+			return err
+		}
+		return bad
+	})
+	if !errors.Is(err, bad) {
+		t.Fatal("应该返回 fn 的错误", err)
+	}
+
+	var nUser *User
+	if err = tUser.Find(context.Background(), dbm.M{"_id": uid}).One(&nUser); !errors.Is(err, dbm.ErrNoDocuments) {
+		t.Fatal("事务应该已经回滚", err)
+	}
+}
+
+func TestDatabase_TxHooks(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var committed, rolledBack bool
+	var err = db.WithTx(context.Background(), func(tx dbm.Tx) error {
+		var ctx, cancel = context.WithCancel(tx)
+		defer cancel()
+
+		var nTx, ok = dbm.TxFromContext(ctx)
+		if !ok {
+			t.Fatal("应该可以从 context 中获取到事务")
+		}
+		nTx.OnCommit(func(ctx context.Context) {
+			committed = true
+		})
+		nTx.OnRollback(func(ctx context.Context) {
+			rolledBack = true
+		})
+
+		_, err := tUser.InsertOne(ctx, &User{Id: dbm.NewObjectId().Hex(), Age: 10, Name: "TxHooks-Good"})
+		return err
+	})
+	if err != nil {
+		t.Fatal("执行事务发生错误", err)
+	}
+
+	if !committed || rolledBack {
+		t.Fatal("只应该执行 commit 回调")
+	}
+}
+
+func TestDatabase_WithTxPropagation(t *testing.T) {
+	var db = getDatabase(t)
+	defer db.Client().Close(context.Background())
+	var tUser = db.Collection("user")
+
+	var uid1 = dbm.NewObjectId().Hex()
+	var uid2 = dbm.NewObjectId().Hex()
+	var err = db.WithTx(context.Background(), func(tx dbm.Tx) error {
+		if _, err := tUser.InsertOne(tx, &User{Id: uid1, Age: 10, Name: "Propagation1-Bad"}); err != nil {
+			return err
+		}
+
+		// 加入外层事务，内层回滚之后外层事务只能回滚
+		var err = db.WithTx(tx, func(inner dbm.Tx) error {
+			if _, err := tUser.InsertOne(inner, &User{Id: uid2, Age: 11, Name: "Propagation2-Bad"}); err != nil {
+				return err
+			}
+			return errors.New("bad")
+		})
+		if err == nil {
+			t.Fatal("内层事务应该返回错误")
+		}
+		return nil
+	})
+	if !errors.Is(err, dbm.ErrTxRollbackOnly) {
+		t.Fatal("外层事务应该返回 ErrTxRollbackOnly", err)
+	}
+
+	var nUsers []*User
+	if err = tUser.Find(context.Background(), dbm.M{"_id": dbm.M{"$in": []string{uid1, uid2}}}).All(&nUsers); err != nil {
+		t.Fatal("查询数据发生错误", err)
+	}
+	if len(nUsers) != 0 {
+		t.Fatal("事务应该已经回滚")
+	}
+}
@@ -21,7 +21,7 @@ type distinct struct {
 	fieldName  string
 	ctx        context.Context
 	opts       *options.DistinctOptions
-	collection Collection
+	collection *collection
 }
 
 func (d *distinct) Collation(c *Collation) Distinct {
@@ -45,7 +45,12 @@ func (d *distinct) Apply(result interface{}) error {
 		return ErrResultNotSlice
 	}
 
-	var data, err = d.collection.Collection().Distinct(d.ctx, d.fieldName, d.filter, d.opts)
+	var data []interface{}
+	var op = &Operation{Name: OpDistinct, Filter: d.filter}
+	var err = d.collection.invoke(d.ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		data, err = d.collection.collection.Distinct(ctx, d.fieldName, op.Filter, d.opts)
+		return err
+	})
 	if err != nil {
 		return err
 	}
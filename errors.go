@@ -2,7 +2,11 @@ package dbm
 
 import (
 	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"strings"
 )
 
 var ErrNoDocuments = mongo.ErrNoDocuments
@@ -10,3 +14,264 @@ var ErrNoDocuments = mongo.ErrNoDocuments
 var ErrSessionNotSupported = errors.New("session not supported")
 
 var ErrResultNotSlice = errors.New("results argument must be a pointer to a slice")
+
+var ErrInvalidPageSize = errors.New("page size must be greater than 0")
+
+var ErrInvalidBatchSize = errors.New("batch size must be greater than 0")
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+var ErrEmptyBulk = errors.New("bulk has no operations")
+
+var ErrEmptyUpdate = errors.New("update has no fields")
+
+var ErrScanWithoutId = errors.New("scan documents must include _id")
+
+var ErrTxRollbackOnly = errors.New("transaction has been marked as rollback-only")
+
+const (
+	errCodeDuplicateKey           = 11000
+	errCodeDuplicateKeyOnUpdate   = 11001
+	errCodeDuplicateKeyOnCapped   = 12582
+	errCodeWriteConflict          = 112
+	errCodeMongosDuplicateKey     = 16460
+	labelTransientTransaction     = "TransientTransactionError"
+	labelUnknownTransactionCommit = "UnknownTransactionCommitResult"
+)
+
+// IsNotFound 判断是否为查询不到数据的错误
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNoDocuments)
+}
+
+// IsDuplicateKey 判断是否为唯一索引冲突的错误，支持 InsertOne、Bulk.Apply、FindUpdate.Apply 等操作返回的错误
+func IsDuplicateKey(err error) bool {
+	return mongo.IsDuplicateKeyError(err)
+}
+
+func IsTimeout(err error) bool {
+	return mongo.IsTimeout(err)
+}
+
+func IsNetworkError(err error) bool {
+	return mongo.IsNetworkError(err)
+}
+
+// IsWriteConflict 判断是否为写冲突的错误，一般发生在多个事务同时修改同一个文档的时候
+func IsWriteConflict(err error) bool {
+	return hasErrorCode(err, errCodeWriteConflict)
+}
+
+// IsTransient 判断是否为临时的事务错误，可以重新执行整个事务
+func IsTransient(err error) bool {
+	return hasErrorLabel(err, labelTransientTransaction)
+}
+
+func hasErrorCode(err error, code int) bool {
+	var sErr mongo.ServerError
+	if errors.As(err, &sErr) {
+		return sErr.HasErrorCode(code)
+	}
+	return false
+}
+
+func hasErrorLabel(err error, label string) bool {
+	var lErr mongo.LabeledError
+	if errors.As(err, &lErr) {
+		return lErr.HasErrorLabel(label)
+	}
+	return false
+}
+
+// DuplicateKeyError 描述一个唯一索引冲突，可以通过 AsDuplicateKey 或者 DuplicateKeys 从操作返回的错误中获取。
+type DuplicateKeyError struct {
+	// WriteIndex 为 Bulk 或者 InsertMany 中发生冲突的操作的下标，其它操作为 0
+	WriteIndex int
+
+	// IndexName 发生冲突的索引名称
+	IndexName string
+
+	// KeyPattern 发生冲突的索引，服务器没有返回时为 nil
+	KeyPattern bson.D
+
+	// KeyValue 发生冲突的值，服务器没有返回时从错误信息中解析，旧版本的服务器返回的字段名可能为空
+	KeyValue bson.D
+
+	Code    int
+	Message string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.Message
+}
+
+// AsDuplicateKey 返回 err 中的第一个唯一索引冲突
+func AsDuplicateKey(err error) (*DuplicateKeyError, bool) {
+	var errs = DuplicateKeys(err)
+	if len(errs) == 0 {
+		return nil, false
+	}
+	return errs[0], true
+}
+
+// DuplicateKeys 返回 err 中的所有唯一索引冲突，Bulk.Apply 和 InsertMany 返回的错误中可能包含多个冲突
+func DuplicateKeys(err error) []*DuplicateKeyError {
+	var dupErr *DuplicateKeyError
+	if errors.As(err, &dupErr) {
+		return []*DuplicateKeyError{dupErr}
+	}
+
+	var errs []*DuplicateKeyError
+	var wErr mongo.WriteException
+	if errors.As(err, &wErr) {
+		for _, we := range wErr.WriteErrors {
+			if isDuplicateKeyCode(we.Code, we.Message) {
+				errs = append(errs, newDuplicateKeyError(we.Index, we.Code, we.Message, we.Raw))
+			}
+		}
+		return errs
+	}
+
+	var bErr mongo.BulkWriteException
+	if errors.As(err, &bErr) {
+		for _, we := range bErr.WriteErrors {
+			if isDuplicateKeyCode(we.Code, we.Message) {
+				errs = append(errs, newDuplicateKeyError(we.Index, we.Code, we.Message, we.Raw))
+			}
+		}
+		return errs
+	}
+
+	var weErr mongo.WriteError
+	if errors.As(err, &weErr) {
+		if isDuplicateKeyCode(weErr.Code, weErr.Message) {
+			errs = append(errs, newDuplicateKeyError(weErr.Index, weErr.Code, weErr.Message, weErr.Raw))
+		}
+		return errs
+	}
+
+	var cErr mongo.CommandError
+	if errors.As(err, &cErr) {
+		if isDuplicateKeyCode(int(cErr.Code), cErr.Message) {
+			errs = append(errs, newDuplicateKeyError(0, int(cErr.Code), cErr.Message, cErr.Raw))
+		}
+		return errs
+	}
+	return nil
+}
+
+func isDuplicateKeyCode(code int, message string) bool {
+	switch code {
+	case errCodeDuplicateKey, errCodeDuplicateKeyOnUpdate, errCodeDuplicateKeyOnCapped:
+		return true
+	case errCodeMongosDuplicateKey:
+		return strings.Contains(message, " E11000 ")
+	}
+	return false
+}
+
+// newDuplicateKeyError 优先使用服务器返回的 keyPattern 和 keyValue（4.2 及以上版本），其次从错误信息中解析
+func newDuplicateKeyError(index, code int, message string, raw bson.Raw) *DuplicateKeyError {
+	var dupErr = &DuplicateKeyError{WriteIndex: index, Code: code, Message: message}
+	dupErr.IndexName, dupErr.KeyValue = parseDuplicateKeyMessage(message)
+
+	if pattern, ok := raw.Lookup("keyPattern").DocumentOK(); ok {
+		_ = bson.Unmarshal(pattern, &dupErr.KeyPattern)
+	}
+	if value, ok := raw.Lookup("keyValue").DocumentOK(); ok {
+		var keyValue bson.D
+		if err := bson.Unmarshal(value, &keyValue); err == nil {
+			dupErr.KeyValue = keyValue
+		}
+	}
+	return dupErr
+}
+
+// parseDuplicateKeyMessage 解析如下格式的错误信息：
+//
+//	E11000 duplicate key error collection: test.user index: name_1 dup key: { name: "smartwalle" }
+func parseDuplicateKeyMessage(message string) (string, bson.D) {
+	var indexName string
+	if pos := strings.Index(message, " index: "); pos >= 0 {
+		var rest = message[pos+len(" index: "):]
+		if end := strings.IndexByte(rest, ' '); end >= 0 {
+			rest = rest[:end]
+		}
+		indexName = rest
+	}
+
+	var pos = strings.Index(message, "dup key: {")
+	if pos < 0 {
+		return indexName, nil
+	}
+	var body = message[pos+len("dup key: {"):]
+	if end := strings.LastIndexByte(body, '}'); end >= 0 {
+		body = body[:end]
+	}
+
+	var keyValue bson.D
+	for _, item := range splitDuplicateKeys(body) {
+		var key, value = item, ""
+		if sep := strings.IndexByte(item, ':'); sep >= 0 {
+			key, value = item[:sep], item[sep+1:]
+		}
+		keyValue = append(keyValue, bson.E{Key: strings.TrimSpace(key), Value: parseDuplicateKeyValue(strings.TrimSpace(value))})
+	}
+	return indexName, keyValue
+}
+
+// splitDuplicateKeys 按照不在字符串、括号中的逗号分割
+func splitDuplicateKeys(body string) []string {
+	var items []string
+	var depth int
+	var quoted, escaped bool
+	var start int
+	for i := 0; i < len(body); i++ {
+		var c = body[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+func parseDuplicateKeyValue(value string) interface{} {
+	switch {
+	case value == "null":
+		return nil
+	case value == "true" || value == "false":
+		return value == "true"
+	case strings.HasPrefix(value, `"`):
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		return strings.Trim(value, `"`)
+	case strings.HasPrefix(value, "ObjectId('") && strings.HasSuffix(value, "')"):
+		if oid, err := primitive.ObjectIDFromHex(value[len("ObjectId('") : len(value)-2]); err == nil {
+			return oid
+		}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package dbm_test
+
+import (
+	"fmt"
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	var tests = []struct {
+		err       error
+		index     int
+		indexName string
+		keyValue  bson.D
+	}{
+		{
+			err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: test.user index: name_1 dup key: { name: "smartwalle" }`,
+			}}},
+			indexName: "name_1",
+			keyValue:  bson.D{{Key: "name", Value: "smartwalle"}},
+		},
+		{
+			err: mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{
+				Index:   2,
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: test.user index: name_1_age_-1 dup key: { name: "a, b", age: 10 }`,
+			}}}},
+			index:     2,
+			indexName: "name_1_age_-1",
+			keyValue:  bson.D{{Key: "name", Value: "a, b"}, {Key: "age", Value: int64(10)}},
+		},
+		{
+			err: fmt.Errorf("wrapped: %w", mongo.CommandError{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: test.user index: _id_ dup key: { _id: "1" }`,
+				Raw:     mustMarshal(bson.D{{Key: "keyPattern", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "keyValue", Value: bson.D{{Key: "_id", Value: "1"}}}}),
+			}),
+			indexName: "_id_",
+			keyValue:  bson.D{{Key: "_id", Value: "1"}},
+		},
+	}
+
+	for _, test := range tests {
+		if !dbm.IsDuplicateKey(test.err) {
+			t.Fatalf("%v 应该是 duplicate key 错误", test.err)
+		}
+
+		var dupErr, ok = dbm.AsDuplicateKey(test.err)
+		if !ok {
+			t.Fatalf("%v 应该可以解析出 DuplicateKeyError", test.err)
+		}
+		if dupErr.WriteIndex != test.index || dupErr.IndexName != test.indexName {
+			t.Fatalf("期望 %d %s，实际 %d %s", test.index, test.indexName, dupErr.WriteIndex, dupErr.IndexName)
+		}
+		if fmt.Sprint(dupErr.KeyValue) != fmt.Sprint(test.keyValue) {
+			t.Fatalf("期望 %v，实际 %v", test.keyValue, dupErr.KeyValue)
+		}
+	}
+
+	if _, ok := dbm.AsDuplicateKey(mongo.CommandError{Code: 112}); ok {
+		t.Fatal("不应该是 duplicate key 错误")
+	}
+	if !dbm.IsWriteConflict(mongo.CommandError{Code: 112}) {
+		t.Fatal("应该是 write conflict 错误")
+	}
+	if !dbm.IsTransient(mongo.CommandError{Labels: []string{"TransientTransactionError"}}) {
+		t.Fatal("应该是 transient 错误")
+	}
+	if !dbm.IsNotFound(fmt.Errorf("wrapped: %w", dbm.ErrNoDocuments)) {
+		t.Fatal("应该是 not found 错误")
+	}
+}
+
+func mustMarshal(v interface{}) bson.Raw {
+	var data, err = bson.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
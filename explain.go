@@ -0,0 +1,283 @@
+package dbm
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type ExplainVerbosity string
+
+const (
+	ExplainQueryPlanner      ExplainVerbosity = "queryPlanner"
+	ExplainExecutionStats    ExplainVerbosity = "executionStats"
+	ExplainAllPlansExecution ExplainVerbosity = "allPlansExecution"
+)
+
+// ExplainResult 是 explain 命令返回结果的摘要，执行相关的统计只有 verbosity 不为 ExplainQueryPlanner 时才有值。
+type ExplainResult struct {
+	// Stage 胜出计划的根 stage，如：FETCH、IXSCAN、COLLSCAN
+	Stage string
+
+	// Stages 胜出计划中从根到叶子的所有 stage
+	Stages []string
+
+	// IndexName 胜出计划中使用的索引，没有使用索引时为空
+	IndexName string
+
+	// IndexNames 胜出计划中使用的所有索引
+	IndexNames []string
+
+	KeysExamined  int64
+	DocsExamined  int64
+	NReturned     int64
+	ExecutionTime time.Duration
+
+	Raw bson.Raw
+}
+
+func (q *query) Explain(verbosity ExplainVerbosity) (*ExplainResult, error) {
+	var opts = q.findOptions()
+	var database = q.collection.collection.Database()
+
+	var raw bson.Raw
+	var op = &Operation{Name: OpExplain, Filter: q.filter}
+	var err = q.collection.invoke(q.ctx, op, func(ctx context.Context, op *Operation) error {
+		var command = findCommand(q.collection.Name(), op.Filter, opts)
+		return runExplain(ctx, database, command, verbosity, &raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseExplain(raw), nil
+}
+
+func (ag *aggregate) Explain(verbosity ExplainVerbosity) (*ExplainResult, error) {
+	var database *mongo.Database
+	var target interface{} = 1
+	switch aggregator := ag.aggregator.(type) {
+	case *mongo.Collection:
+		database = aggregator.Database()
+		target = aggregator.Name()
+	case *mongo.Database:
+		database = aggregator
+	}
+
+	var raw bson.Raw
+	var op = &Operation{Name: OpExplain, Pipeline: ag.pipeline}
+	var err = ag.invoker.invoke(ag.ctx, op, func(ctx context.Context, op *Operation) error {
+		var command = aggregateCommand(target, op.Pipeline, ag.opts)
+		return runExplain(ctx, database, command, verbosity, &raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseExplain(raw), nil
+}
+
+func runExplain(ctx context.Context, database *mongo.Database, command bson.D, verbosity ExplainVerbosity, result *bson.Raw) error {
+	if verbosity == "" {
+		verbosity = ExplainQueryPlanner
+	}
+	var explain = bson.D{
+		{Key: "explain", Value: command},
+		{Key: "verbosity", Value: string(verbosity)},
+	}
+	return database.RunCommand(ctx, explain).Decode(result)
+}
+
+// findCommand 将 FindOptions 转换为 find 命令，MaxAwaitTime 只作用于 getMore 命令，所以会被忽略
+func findCommand(collection string, filter interface{}, opts *options.FindOptions) bson.D {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	var command = bson.D{
+		{Key: "find", Value: collection},
+		{Key: "filter", Value: filter},
+	}
+	if opts.Sort != nil {
+		command = append(command, bson.E{Key: "sort", Value: opts.Sort})
+	}
+	if opts.Projection != nil {
+		command = append(command, bson.E{Key: "projection", Value: opts.Projection})
+	}
+	if opts.Hint != nil {
+		command = append(command, bson.E{Key: "hint", Value: opts.Hint})
+	}
+	if opts.Skip != nil {
+		command = append(command, bson.E{Key: "skip", Value: *opts.Skip})
+	}
+	if opts.Limit != nil {
+		// 与驱动保持一致，limit 为负数时表示只返回一批数据
+		if limit := *opts.Limit; limit < 0 {
+			command = append(command, bson.E{Key: "limit", Value: -limit}, bson.E{Key: "singleBatch", Value: true})
+		} else {
+			command = append(command, bson.E{Key: "limit", Value: limit})
+		}
+	}
+	if opts.BatchSize != nil {
+		command = append(command, bson.E{Key: "batchSize", Value: *opts.BatchSize})
+	}
+	if opts.Collation != nil {
+		command = append(command, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+	}
+	if opts.Comment != nil {
+		command = append(command, bson.E{Key: "comment", Value: *opts.Comment})
+	}
+	if opts.Max != nil {
+		command = append(command, bson.E{Key: "max", Value: opts.Max})
+	}
+	if opts.Min != nil {
+		command = append(command, bson.E{Key: "min", Value: opts.Min})
+	}
+	if opts.MaxTime != nil {
+		command = append(command, bson.E{Key: "maxTimeMS", Value: int64(*opts.MaxTime / time.Millisecond)})
+	}
+	if opts.ReturnKey != nil {
+		command = append(command, bson.E{Key: "returnKey", Value: *opts.ReturnKey})
+	}
+	if opts.ShowRecordID != nil {
+		command = append(command, bson.E{Key: "showRecordId", Value: *opts.ShowRecordID})
+	}
+	if opts.AllowDiskUse != nil {
+		command = append(command, bson.E{Key: "allowDiskUse", Value: *opts.AllowDiskUse})
+	}
+	if opts.AllowPartialResults != nil {
+		command = append(command, bson.E{Key: "allowPartialResults", Value: *opts.AllowPartialResults})
+	}
+	if opts.NoCursorTimeout != nil {
+		command = append(command, bson.E{Key: "noCursorTimeout", Value: *opts.NoCursorTimeout})
+	}
+	if opts.CursorType != nil {
+		switch *opts.CursorType {
+		case options.Tailable:
+			command = append(command, bson.E{Key: "tailable", Value: true})
+		case options.TailableAwait:
+			command = append(command, bson.E{Key: "tailable", Value: true}, bson.E{Key: "awaitData", Value: true})
+		}
+	}
+	if opts.Let != nil {
+		command = append(command, bson.E{Key: "let", Value: opts.Let})
+	}
+	return command
+}
+
+// aggregateCommand 将 AggregateOptions 转换为 aggregate 命令，target 为集合名称或者 1（数据库级别的聚合）
+func aggregateCommand(target interface{}, pipeline interface{}, opts *options.AggregateOptions) bson.D {
+	if pipeline == nil {
+		pipeline = bson.A{}
+	}
+	var command = bson.D{
+		{Key: "aggregate", Value: target},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	if opts.AllowDiskUse != nil {
+		command = append(command, bson.E{Key: "allowDiskUse", Value: *opts.AllowDiskUse})
+	}
+	if opts.BypassDocumentValidation != nil {
+		command = append(command, bson.E{Key: "bypassDocumentValidation", Value: *opts.BypassDocumentValidation})
+	}
+	if opts.Collation != nil {
+		command = append(command, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+	}
+	if opts.Comment != nil {
+		command = append(command, bson.E{Key: "comment", Value: opts.Comment})
+	}
+	if opts.Hint != nil {
+		command = append(command, bson.E{Key: "hint", Value: opts.Hint})
+	}
+	if opts.MaxTime != nil {
+		command = append(command, bson.E{Key: "maxTimeMS", Value: int64(*opts.MaxTime / time.Millisecond)})
+	}
+	if opts.Let != nil {
+		command = append(command, bson.E{Key: "let", Value: opts.Let})
+	}
+	return command
+}
+
+func parseExplain(raw bson.Raw) *ExplainResult {
+	var result = &ExplainResult{Raw: raw}
+
+	// 聚合操作的查询计划可能位于第一个 stage 的 $cursor 中
+	var root = raw
+	if _, err := root.LookupErr("queryPlanner"); err != nil {
+		if cursor, ok := raw.Lookup("stages", "0", "$cursor").DocumentOK(); ok {
+			root = cursor
+		}
+	}
+	// 分片集群中取第一个分片的结果
+	if _, err := root.LookupErr("queryPlanner"); err != nil {
+		if shards, ok := root.Lookup("shards").DocumentOK(); ok {
+			if elems, _ := shards.Elements(); len(elems) > 0 {
+				if shard, ok := elems[0].Value().DocumentOK(); ok {
+					root = shard
+				}
+			}
+		}
+	}
+
+	var plan, _ = root.Lookup("queryPlanner", "winningPlan").DocumentOK()
+	// 分片集群的查询计划
+	if shards, ok := plan.Lookup("shards").ArrayOK(); ok {
+		if shard, err := shards.IndexErr(0); err == nil {
+			if shardPlan, ok := shard.Value().DocumentOK(); ok {
+				plan, _ = shardPlan.Lookup("winningPlan").DocumentOK()
+			}
+		}
+	}
+	// 6.0 之后使用 SBE 引擎时，查询计划位于 queryPlan 中
+	if queryPlan, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		plan = queryPlan
+	}
+	walkPlan(plan, result)
+	if len(result.Stages) > 0 {
+		result.Stage = result.Stages[0]
+	}
+	if len(result.IndexNames) > 0 {
+		result.IndexName = result.IndexNames[0]
+	}
+
+	if stats, ok := root.Lookup("executionStats").DocumentOK(); ok {
+		result.NReturned = rawInt64(stats.Lookup("nReturned"))
+		result.KeysExamined = rawInt64(stats.Lookup("totalKeysExamined"))
+		result.DocsExamined = rawInt64(stats.Lookup("totalDocsExamined"))
+		result.ExecutionTime = time.Duration(rawInt64(stats.Lookup("executionTimeMillis"))) * time.Millisecond
+	}
+	return result
+}
+
+func walkPlan(plan bson.Raw, result *ExplainResult) {
+	if len(plan) == 0 {
+		return
+	}
+	if stage, ok := plan.Lookup("stage").StringValueOK(); ok {
+		result.Stages = append(result.Stages, stage)
+	}
+	if indexName, ok := plan.Lookup("indexName").StringValueOK(); ok {
+		result.IndexNames = append(result.IndexNames, indexName)
+	}
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		walkPlan(input, result)
+	}
+	if inputs, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		var values, _ = inputs.Values()
+		for _, value := range values {
+			if input, ok := value.DocumentOK(); ok {
+				walkPlan(input, result)
+			}
+		}
+	}
+}
+
+func rawInt64(value bson.RawValue) int64 {
+	if n, ok := value.AsInt64OK(); ok {
+		return n
+	}
+	if f, ok := value.DoubleOK(); ok {
+		return int64(f)
+	}
+	return 0
+}
@@ -0,0 +1,218 @@
+package dbm
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindCommand(t *testing.T) {
+	var command = findCommand("user", nil, options.Find())
+	var expected = bson.D{
+		{Key: "find", Value: "user"},
+		{Key: "filter", Value: bson.D{}},
+	}
+	if !reflect.DeepEqual(command, expected) {
+		t.Fatal("find 命令不匹配", command)
+	}
+
+	var opts = options.Find()
+	opts.SetSort(bson.D{{Key: "age", Value: -1}})
+	opts.SetProjection(bson.M{"name": 1})
+	opts.SetHint("age_1")
+	opts.SetSkip(10)
+	opts.SetLimit(-5)
+	opts.SetBatchSize(100)
+	opts.SetComment("explain")
+	opts.SetMaxTime(2 * time.Second)
+	opts.SetAllowDiskUse(true)
+	opts.SetAllowPartialResults(true)
+	opts.SetNoCursorTimeout(true)
+	opts.SetCursorType(options.TailableAwait)
+	opts.SetMaxAwaitTime(time.Second)
+	opts.SetLet(bson.M{"min": 10})
+
+	command = findCommand("user", bson.M{"age": bson.M{"$gt": 10}}, opts)
+	expected = bson.D{
+		{Key: "find", Value: "user"},
+		{Key: "filter", Value: bson.M{"age": bson.M{"$gt": 10}}},
+		{Key: "sort", Value: bson.D{{Key: "age", Value: -1}}},
+		{Key: "projection", Value: bson.M{"name": 1}},
+		{Key: "hint", Value: "age_1"},
+		{Key: "skip", Value: int64(10)},
+		{Key: "limit", Value: int64(5)},
+		{Key: "singleBatch", Value: true},
+		{Key: "batchSize", Value: int32(100)},
+		{Key: "comment", Value: "explain"},
+		{Key: "maxTimeMS", Value: int64(2000)},
+		{Key: "allowDiskUse", Value: true},
+		{Key: "allowPartialResults", Value: true},
+		{Key: "noCursorTimeout", Value: true},
+		{Key: "tailable", Value: true},
+		{Key: "awaitData", Value: true},
+		{Key: "let", Value: bson.M{"min": 10}},
+	}
+	if !reflect.DeepEqual(command, expected) {
+		t.Fatal("find 命令不匹配", command)
+	}
+}
+
+func TestAggregateCommand(t *testing.T) {
+	var command = aggregateCommand(1, nil, options.Aggregate())
+	var expected = bson.D{
+		{Key: "aggregate", Value: 1},
+		{Key: "pipeline", Value: bson.A{}},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	if !reflect.DeepEqual(command, expected) {
+		t.Fatal("aggregate 命令不匹配", command)
+	}
+
+	var pipeline = bson.A{bson.D{{Key: "$match", Value: bson.M{"age": 10}}}}
+	var opts = options.Aggregate()
+	opts.SetAllowDiskUse(true)
+	opts.SetCollation(&options.Collation{Locale: "zh"})
+	opts.SetHint("age_1")
+	opts.SetMaxTime(1500 * time.Millisecond)
+	opts.SetLet(bson.M{"min": 10})
+
+	command = aggregateCommand("user", pipeline, opts)
+	expected = bson.D{
+		{Key: "aggregate", Value: "user"},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+		{Key: "allowDiskUse", Value: true},
+		{Key: "collation", Value: (&options.Collation{Locale: "zh"}).ToDocument()},
+		{Key: "hint", Value: "age_1"},
+		{Key: "maxTimeMS", Value: int64(1500)},
+		{Key: "let", Value: bson.M{"min": 10}},
+	}
+	if !reflect.DeepEqual(command, expected) {
+		t.Fatal("aggregate 命令不匹配", command)
+	}
+}
+
+func TestParseExplain(t *testing.T) {
+	var ixscan = bson.D{
+		{Key: "stage", Value: "FETCH"},
+		{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "age_1"}}},
+	}
+	var orPlan = bson.D{
+		{Key: "stage", Value: "OR"},
+		{Key: "inputStages", Value: bson.A{
+			bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "age_1"}},
+			bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "name_1"}},
+		}},
+	}
+	var stats = bson.D{
+		{Key: "nReturned", Value: int32(3)},
+		{Key: "totalKeysExamined", Value: int32(4)},
+		{Key: "totalDocsExamined", Value: int64(3)},
+		{Key: "executionTimeMillis", Value: int32(12)},
+	}
+
+	var tests = []struct {
+		name     string
+		doc      bson.D
+		stages   []string
+		indexes  []string
+		returned int64
+	}{
+		{
+			name: "unsharded",
+			doc: bson.D{
+				{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: ixscan}}},
+				{Key: "executionStats", Value: stats},
+			},
+			stages:   []string{"FETCH", "IXSCAN"},
+			indexes:  []string{"age_1"},
+			returned: 3,
+		},
+		{
+			name: "unsharded sbe",
+			doc: bson.D{
+				{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+					{Key: "queryPlan", Value: ixscan},
+					{Key: "slotBasedPlan", Value: bson.D{{Key: "stages", Value: "..."}}},
+				}}}},
+			},
+			stages:  []string{"FETCH", "IXSCAN"},
+			indexes: []string{"age_1"},
+		},
+		{
+			name: "unsharded collscan",
+			doc: bson.D{
+				{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}}}},
+			},
+			stages: []string{"COLLSCAN"},
+		},
+		{
+			name: "sharded",
+			doc: bson.D{
+				{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+					{Key: "stage", Value: "SINGLE_SHARD"},
+					{Key: "shards", Value: bson.A{bson.D{
+						{Key: "shardName", Value: "shard-0"},
+						{Key: "winningPlan", Value: ixscan},
+					}}},
+				}}}},
+				{Key: "executionStats", Value: stats},
+			},
+			stages:   []string{"FETCH", "IXSCAN"},
+			indexes:  []string{"age_1"},
+			returned: 3,
+		},
+		{
+			name: "sharded aggregate",
+			doc: bson.D{
+				{Key: "shards", Value: bson.D{
+					{Key: "shard-0", Value: bson.D{
+						{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: ixscan}}},
+						{Key: "executionStats", Value: stats},
+					}},
+				}},
+			},
+			stages:   []string{"FETCH", "IXSCAN"},
+			indexes:  []string{"age_1"},
+			returned: 3,
+		},
+		{
+			name: "aggregate cursor",
+			doc: bson.D{
+				{Key: "stages", Value: bson.A{
+					bson.D{{Key: "$cursor", Value: bson.D{
+						{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: orPlan}}},
+					}}},
+					bson.D{{Key: "$group", Value: bson.D{}}},
+				}},
+			},
+			stages:  []string{"OR", "IXSCAN", "IXSCAN"},
+			indexes: []string{"age_1", "name_1"},
+		},
+	}
+
+	for _, test := range tests {
+		var raw, err = bson.Marshal(test.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result = parseExplain(raw)
+		if !reflect.DeepEqual(result.Stages, test.stages) || !reflect.DeepEqual(result.IndexNames, test.indexes) {
+			t.Fatalf("%s: 查询计划不匹配 %v %v", test.name, result.Stages, result.IndexNames)
+		}
+		if result.Stage != test.stages[0] {
+			t.Fatalf("%s: 根 stage 不匹配 %s", test.name, result.Stage)
+		}
+		if len(test.indexes) > 0 && result.IndexName != test.indexes[0] {
+			t.Fatalf("%s: 索引不匹配 %s", test.name, result.IndexName)
+		}
+		if result.NReturned != test.returned {
+			t.Fatalf("%s: nReturned 不匹配 %d", test.name, result.NReturned)
+		}
+		if test.returned > 0 && (result.KeysExamined != 4 || result.DocsExamined != 3 || result.ExecutionTime != 12*time.Millisecond) {
+			t.Fatalf("%s: 执行统计不匹配 %+v", test.name, result)
+		}
+	}
+}
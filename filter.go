@@ -0,0 +1,248 @@
+package dbm
+
+import (
+	"reflect"
+)
+
+// Filter 用于构建查询条件，可以用于所有接收 filter 参数的方法，如 Collection.Find、Collection.DeleteMany、Bulk.UpdateMany 等。
+//
+//	var filter = dbm.F().Eq("name", "smartwalle").Gt("age", 10).Lt("age", 20).In("_id", ids)
+//
+// 同一个字段上的多个操作符会被合并，如上面的 age 字段会生成 {"age": {"$gt": 10, "$lt": 20}}。
+type Filter D
+
+// operators 为同一个字段上的操作符，用于和普通的值区分
+type operators D
+
+func F() *Filter {
+	return &Filter{}
+}
+
+// D 返回构建的查询条件
+func (f *Filter) D() D {
+	var d = make(D, len(*f))
+	copy(d, *f)
+	return d
+}
+
+// Op 为字段 key 添加操作符 op，op 需要以 $ 开头，可以用于添加 Filter 没有提供的操作符
+func (f *Filter) Op(key, op string, value interface{}) *Filter {
+	for i := range *f {
+		var elem = &(*f)[i]
+		if elem.Key != key {
+			continue
+		}
+
+		var ops, ok = elem.Value.(operators)
+		if !ok {
+			// 字段上已经存在相等条件，转换为 $eq
+			ops = operators{{Key: "$eq", Value: elem.Value}}
+		}
+		elem.Value = setOperator(ops, op, value)
+		return f
+	}
+	*f = append(*f, E{Key: key, Value: operators{{Key: op, Value: value}}})
+	return f
+}
+
+func setOperator(ops operators, op string, value interface{}) operators {
+	for i := range ops {
+		if ops[i].Key == op {
+			ops[i].Value = value
+			return ops
+		}
+	}
+	return append(ops, E{Key: op, Value: value})
+}
+
+// Eq 生成 {key: value}，字段上已经存在其它操作符时生成 {key: {$eq: value}}
+func (f *Filter) Eq(key string, value interface{}) *Filter {
+	for _, elem := range *f {
+		if elem.Key == key {
+			return f.Op(key, "$eq", value)
+		}
+	}
+	*f = append(*f, E{Key: key, Value: value})
+	return f
+}
+
+func (f *Filter) Ne(key string, value interface{}) *Filter {
+	return f.Op(key, "$ne", value)
+}
+
+func (f *Filter) Gt(key string, value interface{}) *Filter {
+	return f.Op(key, "$gt", value)
+}
+
+func (f *Filter) Gte(key string, value interface{}) *Filter {
+	return f.Op(key, "$gte", value)
+}
+
+func (f *Filter) Lt(key string, value interface{}) *Filter {
+	return f.Op(key, "$lt", value)
+}
+
+func (f *Filter) Lte(key string, value interface{}) *Filter {
+	return f.Op(key, "$lte", value)
+}
+
+// In values 为 slice 或者 array
+func (f *Filter) In(key string, values interface{}) *Filter {
+	return f.Op(key, "$in", values)
+}
+
+// Nin values 为 slice 或者 array
+func (f *Filter) Nin(key string, values interface{}) *Filter {
+	return f.Op(key, "$nin", values)
+}
+
+// Not 生成 {key: {$not: expr}}，expr 为操作符表达式或者正则表达式
+func (f *Filter) Not(key string, expr interface{}) *Filter {
+	if filter, ok := expr.(*Filter); ok {
+		expr = filter.D()
+	}
+	return f.Op(key, "$not", expr)
+}
+
+func (f *Filter) Exists(key string, exists bool) *Filter {
+	return f.Op(key, "$exists", exists)
+}
+
+// Type t 为 BSON 类型的名称或者编号，如 "string"、2
+func (f *Filter) Type(key string, t interface{}) *Filter {
+	return f.Op(key, "$type", t)
+}
+
+func (f *Filter) Mod(key string, divisor, remainder int64) *Filter {
+	return f.Op(key, "$mod", A{divisor, remainder})
+}
+
+func (f *Filter) Regex(key, pattern, options string) *Filter {
+	return f.Op(key, "$regex", NR(pattern, options))
+}
+
+// All values 为 slice 或者 array
+func (f *Filter) All(key string, values interface{}) *Filter {
+	return f.Op(key, "$all", values)
+}
+
+func (f *Filter) ElemMatch(key string, filter interface{}) *Filter {
+	return f.Op(key, "$elemMatch", filter)
+}
+
+func (f *Filter) Size(key string, size int) *Filter {
+	return f.Op(key, "$size", size)
+}
+
+// GeoWithin 生成 {key: {$geoWithin: {$geometry: geometry}}}
+func (f *Filter) GeoWithin(key string, geometry interface{}) *Filter {
+	return f.Op(key, "$geoWithin", D{{Key: "$geometry", Value: geometry}})
+}
+
+// GeoIntersects 生成 {key: {$geoIntersects: {$geometry: geometry}}}
+func (f *Filter) GeoIntersects(key string, geometry interface{}) *Filter {
+	return f.Op(key, "$geoIntersects", D{{Key: "$geometry", Value: geometry}})
+}
+
+// Near 查询距离 geometry 由近到远的文档，maxDistance 和 minDistance 的单位为米，为 0 时表示不限制
+func (f *Filter) Near(key string, geometry interface{}, maxDistance, minDistance float64) *Filter {
+	return f.Op(key, "$near", nearValue(geometry, maxDistance, minDistance))
+}
+
+// NearSphere 与 Near 相同，使用球面几何计算距离
+func (f *Filter) NearSphere(key string, geometry interface{}, maxDistance, minDistance float64) *Filter {
+	return f.Op(key, "$nearSphere", nearValue(geometry, maxDistance, minDistance))
+}
+
+func nearValue(geometry interface{}, maxDistance, minDistance float64) D {
+	var value = D{{Key: "$geometry", Value: geometry}}
+	if maxDistance > 0 {
+		value = append(value, E{Key: "$maxDistance", Value: maxDistance})
+	}
+	if minDistance > 0 {
+		value = append(value, E{Key: "$minDistance", Value: minDistance})
+	}
+	return value
+}
+
+// GeoPoint 返回 GeoJSON 格式的点
+func GeoPoint(longitude, latitude float64) D {
+	return D{{Key: "type", Value: "Point"}, {Key: "coordinates", Value: A{longitude, latitude}}}
+}
+
+// Expr 生成 {$expr: expr}，可以在查询条件中使用聚合表达式
+func (f *Filter) Expr(expr interface{}) *Filter {
+	return f.set("$expr", expr)
+}
+
+// Text 生成 {$text: {$search: search}}，需要集合上存在 text 索引
+func (f *Filter) Text(search string) *Filter {
+	return f.set("$text", D{{Key: "$search", Value: search}})
+}
+
+// And 生成 {$and: [filters...]}，多次调用时合并到同一个 $and 中
+func (f *Filter) And(filters ...interface{}) *Filter {
+	for i := range *f {
+		if (*f)[i].Key == "$and" {
+			(*f)[i].Value = append(logicalValues((*f)[i].Value), filters...)
+			return f
+		}
+	}
+	*f = append(*f, E{Key: "$and", Value: A(filters)})
+	return f
+}
+
+// logicalValues 将已经存在的 $and 的值转换为 A，值可能是通过 Eq 等方法设置的 []M、[]D 等类型，不是数组时作为一个条件
+func logicalValues(value interface{}) A {
+	switch v := value.(type) {
+	case A:
+		return v
+	case []interface{}:
+		return A(v)
+	case nil:
+		return A{}
+	case D, M, operators, Filter, *Filter:
+		return A{v}
+	}
+
+	var rv = reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return A{value}
+	}
+	var values = make(A, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		values = append(values, rv.Index(i).Interface())
+	}
+	return values
+}
+
+// Or 生成 {$or: [filters...]}，多次调用时每个 $or 都需要满足，会被合并到 $and 中
+func (f *Filter) Or(filters ...interface{}) *Filter {
+	return f.logical("$or", filters)
+}
+
+// Nor 生成 {$nor: [filters...]}，多次调用时合并到 $and 中
+func (f *Filter) Nor(filters ...interface{}) *Filter {
+	return f.logical("$nor", filters)
+}
+
+func (f *Filter) logical(op string, filters []interface{}) *Filter {
+	for _, elem := range *f {
+		if elem.Key == op {
+			return f.And(D{{Key: op, Value: A(filters)}})
+		}
+	}
+	*f = append(*f, E{Key: op, Value: A(filters)})
+	return f
+}
+
+// set 设置顶层的条件，已经存在时合并到 $and 中
+func (f *Filter) set(key string, value interface{}) *Filter {
+	for _, elem := range *f {
+		if elem.Key == key {
+			return f.And(D{{Key: key, Value: value}})
+		}
+	}
+	*f = append(*f, E{Key: key, Value: value})
+	return f
+}
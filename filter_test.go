@@ -0,0 +1,65 @@
+package dbm_test
+
+import (
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	var tests = []struct {
+		filter   *dbm.Filter
+		expected string
+	}{
+		{
+			filter:   dbm.F().Eq("name", "smartwalle").Gt("age", 10).Lt("age", 20),
+			expected: `{"name":"smartwalle","age":{"$gt":10,"$lt":20}}`,
+		},
+		{
+			filter:   dbm.F().Eq("age", 10).Ne("age", 11),
+			expected: `{"age":{"$eq":10,"$ne":11}}`,
+		},
+		{
+			filter:   dbm.F().In("_id", []string{"1", "2"}).Exists("deleted", false),
+			expected: `{"_id":{"$in":["1","2"]},"deleted":{"$exists":false}}`,
+		},
+		{
+			filter:   dbm.F().Or(dbm.F().Eq("a", 1), dbm.F().Eq("b", 2)).Or(dbm.M{"c": 3}, dbm.M{"d": 4}),
+			expected: `{"$or":[{"a":1},{"b":2}],"$and":[{"$or":[{"c":3},{"d":4}]}]}`,
+		},
+		{
+			filter:   dbm.F().Not("name", dbm.NR("^a", "i")).Size("tags", 2),
+			expected: `{"name":{"$not":{"$regularExpression":{"pattern":"^a","options":"i"}}},"tags":{"$size":2}}`,
+		},
+		{
+			filter:   dbm.F().ElemMatch("items", dbm.F().Gte("qty", 1)).Expr(dbm.M{"$gt": dbm.A{"$spent", "$budget"}}),
+			expected: `{"items":{"$elemMatch":{"qty":{"$gte":1}}},"$expr":{"$gt":["$spent","$budget"]}}`,
+		},
+		{
+			filter:   dbm.F().Eq("$and", []dbm.M{{"a": 1}, {"b": 2}}).And(dbm.M{"c": 3}),
+			expected: `{"$and":[{"a":1},{"b":2},{"c":3}]}`,
+		},
+		{
+			filter:   dbm.F().Eq("$and", dbm.D{{Key: "a", Value: 1}}).And(dbm.M{"c": 3}),
+			expected: `{"$and":[{"a":1},{"c":3}]}`,
+		},
+		{
+			filter:   (&dbm.Filter{{Key: "$and", Value: []interface{}{dbm.M{"a": 1}}}}).Or(dbm.M{"b": 2}).Or(dbm.M{"c": 3}),
+			expected: `{"$and":[{"a":1},{"$or":[{"c":3}]}],"$or":[{"b":2}]}`,
+		},
+		{
+			filter:   dbm.F().Near("location", dbm.GeoPoint(113.3, 23.1), 1000, 0),
+			expected: `{"location":{"$near":{"$geometry":{"type":"Point","coordinates":[113.3,23.1]},"$maxDistance":1000.0}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		var data, err = bson.MarshalExtJSON(test.filter, false, false)
+		if err != nil {
+			t.Fatal("转换查询条件发生错误", err)
+		}
+		if string(data) != test.expected {
+			t.Fatalf("期望 %s，实际 %s", test.expected, string(data))
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
 )
 
 type IndexOptions = options.IndexOptions
@@ -30,6 +32,87 @@ type IndexView interface {
 	Drop(ctx context.Context, name string) error
 
 	DropAll(ctx context.Context) error
+
+	List(ctx context.Context) ([]IndexSpec, error)
+
+	Exists(ctx context.Context, keys []string) (bool, error)
+}
+
+// IndexSpec 描述数据库中已存在的索引。
+type IndexSpec struct {
+	Name                    string
+	Keys                    bson.D
+	Unique                  bool
+	Sparse                  bool
+	ExpireAfterSeconds      *int32
+	PartialFilterExpression bson.Raw
+	Collation               *Collation
+	Weights                 bson.D
+	Hidden                  bool
+	Version                 int32
+}
+
+// KeyFields 将索引的 key 转换为 +field/-field 形式。
+func (spec IndexSpec) KeyFields() []string {
+	return formatIndexKey(spec.Keys, spec.Weights)
+}
+
+// Match 判断索引的 key 是否与 keys 完全一致，只比较 key，不比较索引名称，keys 的格式与 IndexView.Create 相同。
+func (spec IndexSpec) Match(keys []string) bool {
+	return sameIndexKey(spec.KeyFields(), normalizeIndexKey(keys))
+}
+
+type indexDocument struct {
+	Name                    string             `bson:"name"`
+	Key                     bson.D             `bson:"key"`
+	Unique                  bool               `bson:"unique"`
+	Sparse                  bool               `bson:"sparse"`
+	ExpireAfterSeconds      *int32             `bson:"expireAfterSeconds"`
+	PartialFilterExpression bson.Raw           `bson:"partialFilterExpression"`
+	Collation               *collationDocument `bson:"collation"`
+	Weights                 bson.D             `bson:"weights"`
+	Hidden                  bool               `bson:"hidden"`
+	Version                 int32              `bson:"v"`
+}
+
+// collationDocument 用于解析索引中的 collation，options.Collation 的字段名称和服务器返回的不一致
+type collationDocument struct {
+	Locale          string `bson:"locale"`
+	CaseLevel       bool   `bson:"caseLevel"`
+	CaseFirst       string `bson:"caseFirst"`
+	Strength        int    `bson:"strength"`
+	NumericOrdering bool   `bson:"numericOrdering"`
+	Alternate       string `bson:"alternate"`
+	MaxVariable     string `bson:"maxVariable"`
+	Normalization   bool   `bson:"normalization"`
+	Backwards       bool   `bson:"backwards"`
+}
+
+func (doc *indexDocument) spec() IndexSpec {
+	var spec = IndexSpec{}
+	spec.Name = doc.Name
+	spec.Keys = doc.Key
+	spec.Unique = doc.Unique
+	spec.Sparse = doc.Sparse
+	spec.ExpireAfterSeconds = doc.ExpireAfterSeconds
+	spec.PartialFilterExpression = doc.PartialFilterExpression
+	spec.Weights = doc.Weights
+	spec.Hidden = doc.Hidden
+	spec.Version = doc.Version
+	if c := doc.Collation; c != nil {
+		spec.Collation = &Collation{
+			Locale:          c.Locale,
+			CaseLevel:       c.CaseLevel,
+			CaseFirst:       c.CaseFirst,
+			Strength:        c.Strength,
+			NumericOrdering: c.NumericOrdering,
+			Alternate:       c.Alternate,
+			MaxVariable:     c.MaxVariable,
+			Normalization:   c.Normalization,
+			Backwards:       c.Backwards,
+		}
+	}
+	return spec
 }
 
 type indexView struct {
@@ -67,21 +150,57 @@ func (iv *indexView) CreateTTLIndex(ctx context.Context, name string, keys []str
 	return iv.Create(ctx, keys, opts)
 }
 
+const (
+	IndexText     = "text"
+	Index2dSphere = "2dsphere"
+	Index2d       = "2d"
+	IndexHashed   = "hashed"
+)
+
+// parseIndexKey 解析索引的 key，支持以下格式：
+//
+//	+field 或 field：升序索引
+//	-field：降序索引
+//	$text:field、$2dsphere:field、$2d:field、$hashed:field：特殊类型的索引
+//	$** 或 field.$**：通配符索引
 func parseIndexKey(keys []string) bson.D {
 	var doc bson.D
 	for _, field := range keys {
-		var sort = int32(1)
-		field, sort = sortField(field)
-		doc = append(doc, bson.E{field, sort})
+		if kind, key, ok := indexKind(field); ok {
+			doc = append(doc, bson.E{Key: key, Value: kind})
+			continue
+		}
+		var key, order = sortField(field)
+		doc = append(doc, bson.E{Key: key, Value: order})
 	}
 	return doc
 }
 
+func indexKind(field string) (kind, key string, ok bool) {
+	if len(field) == 0 || field[0] != '$' {
+		return "", "", false
+	}
+	var c = strings.Index(field, ":")
+	if c <= 1 || c == len(field)-1 {
+		return "", "", false
+	}
+	switch kind = field[1:c]; kind {
+	case IndexText, Index2dSphere, Index2d, IndexHashed:
+		return kind, field[c+1:], true
+	}
+	return "", "", false
+}
+
 func (iv *indexView) DropIndex(ctx context.Context, keys []string) error {
+	_, err := iv.view.DropOne(ctx, indexName(keys))
+	return err
+}
+
+// indexName 按照 mongodb 的规则生成默认的索引名称，如：name_1_age_-1、title_text
+func indexName(keys []string) string {
 	var name string
-	for _, key := range keys {
-		field, sort := sortField(key)
-		field = field + "_" + fmt.Sprint(sort)
+	for _, elem := range parseIndexKey(keys) {
+		var field = elem.Key + "_" + fmt.Sprint(elem.Value)
 
 		if name == "" {
 			name = field
@@ -89,8 +208,84 @@ func (iv *indexView) DropIndex(ctx context.Context, keys []string) error {
 			name += "_" + field
 		}
 	}
-	_, err := iv.view.DropOne(ctx, name)
-	return err
+	return name
+}
+
+// formatIndexKey 将索引的 key 文档转换为 +field/-field/$kind:field 形式。
+//
+// 服务器返回的文本索引 key 为 {_fts: "text", _ftsx: 1}，需要通过 weights 还原出具体的字段。
+func formatIndexKey(doc bson.D, weights bson.D) []string {
+	var keys = make([]string, 0, len(doc))
+	for _, elem := range doc {
+		switch value := elem.Value.(type) {
+		case string:
+			if elem.Key == "_fts" && value == IndexText && len(weights) > 0 {
+				for _, weight := range weights {
+					keys = append(keys, "$"+IndexText+":"+weight.Key)
+				}
+				continue
+			}
+			keys = append(keys, "$"+value+":"+elem.Key)
+		default:
+			if elem.Key == "_ftsx" {
+				continue
+			}
+			if n, ok := numberValue(value); ok && n < 0 {
+				keys = append(keys, "-"+elem.Key)
+			} else {
+				keys = append(keys, "+"+elem.Key)
+			}
+		}
+	}
+	return sortTextKey(keys)
+}
+
+// sortTextKey 文本索引中字段的顺序没有意义，对连续的文本字段进行排序以便于比较
+func sortTextKey(keys []string) []string {
+	var prefix = "$" + IndexText + ":"
+	for i := 0; i < len(keys); {
+		if !strings.HasPrefix(keys[i], prefix) {
+			i++
+			continue
+		}
+		var j = i
+		for j < len(keys) && strings.HasPrefix(keys[j], prefix) {
+			j++
+		}
+		sort.Strings(keys[i:j])
+		i = j
+	}
+	return keys
+}
+
+func normalizeIndexKey(keys []string) []string {
+	return formatIndexKey(parseIndexKey(keys), nil)
+}
+
+func sameIndexKey(keys1, keys2 []string) bool {
+	if len(keys1) != len(keys2) {
+		return false
+	}
+	for i := range keys1 {
+		if keys1[i] != keys2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func numberValue(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
 }
 
 func (iv *indexView) Drop(ctx context.Context, name string) error {
@@ -102,3 +297,34 @@ func (iv *indexView) DropAll(ctx context.Context) error {
 	_, err := iv.view.DropAll(ctx)
 	return err
 }
+
+func (iv *indexView) List(ctx context.Context) ([]IndexSpec, error) {
+	var cur, err = iv.view.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*indexDocument
+	if err = cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var specs = make([]IndexSpec, 0, len(docs))
+	for _, doc := range docs {
+		specs = append(specs, doc.spec())
+	}
+	return specs, nil
+}
+
+// Exists 判断是否存在 key 与 keys 完全一致的索引，keys 的格式与 Create 相同，不会按照索引名称匹配。
+func (iv *indexView) Exists(ctx context.Context, keys []string) (bool, error) {
+	var specs, err = iv.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, spec := range specs {
+		if spec.Match(keys) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,363 @@
+package dbm
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Index 描述一个需要同步到数据库的索引。
+//
+// Keys 使用和 IndexView.Create 相同的 +field/-field 格式；Name 为空时使用 mongodb 默认的索引名称。
+type Index struct {
+	Name               string
+	Keys               []string
+	Unique             bool
+	Sparse             bool
+	ExpireAfterSeconds *int32
+	PartialFilter      interface{}
+}
+
+func (idx Index) name() string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+	return indexName(idx.Keys)
+}
+
+func (idx Index) options() *IndexOptions {
+	var opts = NewIndexOptions()
+	opts.SetName(idx.name())
+	if idx.Unique {
+		opts.SetUnique(true)
+	}
+	if idx.Sparse {
+		opts.SetSparse(true)
+	}
+	if idx.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*idx.ExpireAfterSeconds)
+	}
+	if idx.PartialFilter != nil {
+		opts.SetPartialFilterExpression(idx.PartialFilter)
+	}
+	return opts
+}
+
+// IndexDeclarer 由需要声明索引的模型实现，用于声明无法通过 struct tag 表达的索引，如 partial 索引。
+type IndexDeclarer interface {
+	Indexes() []Index
+}
+
+// ParseIndexes 解析模型上声明的索引，包括 dbm struct tag 和 IndexDeclarer 接口返回的索引。
+//
+// struct tag 格式如下，多个索引之间使用 ; 分隔，名称相同的字段按照字段顺序组成复合索引：
+//
+//	Name  string    `bson:"name" dbm:"index:idx_name_age,unique"`
+//	Age   int       `bson:"age" dbm:"index:idx_name_age,desc;index"`
+//	Until time.Time `bson:"until" dbm:"index:idx_until,ttl=3600"`
+//
+// 支持的选项有 unique、sparse、desc、ttl=秒数，以及 text、2dsphere、2d、hashed 等特殊索引类型。
+func ParseIndexes(model interface{}) ([]Index, error) {
+	var mType = reflect.TypeOf(model)
+	for mType != nil && mType.Kind() == reflect.Ptr {
+		mType = mType.Elem()
+	}
+	if mType == nil || mType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dbm: model must be a struct or a pointer to struct, got %T", model)
+	}
+
+	var p = &indexParser{named: make(map[string]int)}
+	if err := p.parseStruct(mType, "", make(map[reflect.Type]bool)); err != nil {
+		return nil, err
+	}
+
+	if declarer, ok := model.(IndexDeclarer); ok {
+		p.indexes = append(p.indexes, declarer.Indexes()...)
+	}
+
+	var names = make(map[string]struct{}, len(p.indexes))
+	for _, idx := range p.indexes {
+		if len(idx.Keys) == 0 {
+			return nil, fmt.Errorf("dbm: index %s has no keys", idx.Name)
+		}
+		var name = idx.name()
+		if _, exists := names[name]; exists {
+			return nil, fmt.Errorf("dbm: duplicate index %s", name)
+		}
+		names[name] = struct{}{}
+	}
+	return p.indexes, nil
+}
+
+type indexParser struct {
+	indexes []Index
+	named   map[string]int
+}
+
+func (p *indexParser) parseStruct(sType reflect.Type, prefix string, visited map[reflect.Type]bool) error {
+	if visited[sType] {
+		return nil
+	}
+	visited[sType] = true
+	defer delete(visited, sType)
+
+	for i := 0; i < sType.NumField(); i++ {
+		var field = sType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		var tags, err = bsoncodec.DefaultStructTagParser.ParseStructTags(field)
+		if err != nil {
+			return err
+		}
+		if tags.Skip {
+			continue
+		}
+
+		var path = prefix + tags.Name
+		if tags.Inline {
+			path = prefix
+		}
+
+		if tag, ok := field.Tag.Lookup("dbm"); ok && !tags.Inline {
+			if err = p.parseTag(path, tag); err != nil {
+				return fmt.Errorf("dbm: field %s: %w", field.Name, err)
+			}
+		}
+
+		var fType = field.Type
+		for fType.Kind() == reflect.Ptr {
+			fType = fType.Elem()
+		}
+		if fType.Kind() == reflect.Struct && fType.PkgPath() != "time" {
+			var nPrefix = path + "."
+			if tags.Inline {
+				nPrefix = prefix
+			}
+			if err = p.parseStruct(fType, nPrefix, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *indexParser) parseTag(path, tag string) error {
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var items = strings.Split(part, ",")
+		var head = strings.TrimSpace(items[0])
+		if head != "index" && !strings.HasPrefix(head, "index:") {
+			return fmt.Errorf("unknown tag %q", part)
+		}
+
+		var idx = Index{Name: strings.TrimPrefix(strings.TrimPrefix(head, "index"), ":")}
+		var key = "+" + path
+		for _, item := range items[1:] {
+			item = strings.TrimSpace(item)
+			switch {
+			case item == "unique":
+				idx.Unique = true
+			case item == "sparse":
+				idx.Sparse = true
+			case item == "desc":
+				key = "-" + path
+			case item == IndexText || item == Index2dSphere || item == Index2d || item == IndexHashed:
+				key = "$" + item + ":" + path
+			case strings.HasPrefix(item, "ttl="):
+				var ttl, err = strconv.ParseInt(item[len("ttl="):], 10, 32)
+				if err != nil {
+					return fmt.Errorf("invalid ttl %q", item)
+				}
+				var seconds = int32(ttl)
+				idx.ExpireAfterSeconds = &seconds
+			case item == "":
+			default:
+				return fmt.Errorf("unknown index option %q", item)
+			}
+		}
+		idx.Keys = []string{key}
+
+		if idx.Name == "" {
+			p.indexes = append(p.indexes, idx)
+			continue
+		}
+
+		// 名称相同的字段组成复合索引
+		if pos, exists := p.named[idx.Name]; exists {
+			var nIdx = &p.indexes[pos]
+			nIdx.Keys = append(nIdx.Keys, key)
+			nIdx.Unique = nIdx.Unique || idx.Unique
+			nIdx.Sparse = nIdx.Sparse || idx.Sparse
+			if idx.ExpireAfterSeconds != nil {
+				nIdx.ExpireAfterSeconds = idx.ExpireAfterSeconds
+			}
+			continue
+		}
+		p.named[idx.Name] = len(p.indexes)
+		p.indexes = append(p.indexes, idx)
+	}
+	return nil
+}
+
+type SyncIndexOptions struct {
+	// DropStale 为 true 时删除数据库中存在但是模型中没有声明的索引
+	DropStale bool
+
+	// RecreateDrifted 为 true 时删除并重新创建 key 或者选项发生变化的索引
+	RecreateDrifted bool
+}
+
+func NewSyncIndexOptions() *SyncIndexOptions {
+	return &SyncIndexOptions{}
+}
+
+func (opts *SyncIndexOptions) SetDropStale(b bool) *SyncIndexOptions {
+	opts.DropStale = b
+	return opts
+}
+
+func (opts *SyncIndexOptions) SetRecreateDrifted(b bool) *SyncIndexOptions {
+	opts.RecreateDrifted = b
+	return opts
+}
+
+type SyncIndexResult struct {
+	// Created 新创建的索引
+	Created []string
+
+	// Dropped 被删除的索引
+	Dropped []string
+
+	// Stale 数据库中存在但是模型中没有声明的索引
+	Stale []string
+
+	// Drifted key 或者选项与声明不一致的索引
+	Drifted []string
+}
+
+// SyncIndexes 将模型上声明的索引同步到 Collection。
+//
+// 缺失的索引会被创建；多余的索引和发生变化的索引默认只会记录在结果中，需要通过 SyncIndexOptions 开启删除。
+func SyncIndexes(ctx context.Context, c Collection, model interface{}, opts ...*SyncIndexOptions) (*SyncIndexResult, error) {
+	var opt = NewSyncIndexOptions()
+	for _, o := range opts {
+		if o != nil {
+			opt.DropStale = opt.DropStale || o.DropStale
+			opt.RecreateDrifted = opt.RecreateDrifted || o.RecreateDrifted
+		}
+	}
+
+	declared, err := ParseIndexes(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var view = c.IndexView()
+	existing, err := view.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = &SyncIndexResult{}
+	var matched = make(map[string]bool, len(existing))
+
+	for _, idx := range declared {
+		var name = idx.name()
+		var current = findIndex(existing, name, idx.Keys)
+		if current != nil {
+			matched[current.Name] = true
+			if current.Name == name && !indexDrifted(idx, current) {
+				continue
+			}
+
+			result.Drifted = append(result.Drifted, current.Name)
+			if !opt.RecreateDrifted {
+				continue
+			}
+			if err = view.Drop(ctx, current.Name); err != nil {
+				return result, err
+			}
+			result.Dropped = append(result.Dropped, current.Name)
+		}
+
+		if _, err = view.Create(ctx, idx.Keys, idx.options()); err != nil {
+			return result, err
+		}
+		result.Created = append(result.Created, name)
+	}
+
+	for _, current := range existing {
+		if current.Name == "_id_" || matched[current.Name] {
+			continue
+		}
+		result.Stale = append(result.Stale, current.Name)
+		if !opt.DropStale {
+			continue
+		}
+		if err = view.Drop(ctx, current.Name); err != nil {
+			return result, err
+		}
+		result.Dropped = append(result.Dropped, current.Name)
+	}
+	return result, nil
+}
+
+// findIndex 优先按照名称查找索引，其次按照 key 查找
+func findIndex(specs []IndexSpec, name string, keys []string) *IndexSpec {
+	for i := range specs {
+		if specs[i].Name == name {
+			return &specs[i]
+		}
+	}
+	for i := range specs {
+		if specs[i].Match(keys) {
+			return &specs[i]
+		}
+	}
+	return nil
+}
+
+func indexDrifted(idx Index, current *IndexSpec) bool {
+	if !current.Match(idx.Keys) {
+		return true
+	}
+	if idx.Unique != current.Unique || idx.Sparse != current.Sparse {
+		return true
+	}
+	if (idx.ExpireAfterSeconds == nil) != (current.ExpireAfterSeconds == nil) {
+		return true
+	}
+	if idx.ExpireAfterSeconds != nil && *idx.ExpireAfterSeconds != *current.ExpireAfterSeconds {
+		return true
+	}
+	return !samePartialFilter(idx.PartialFilter, current.PartialFilterExpression)
+}
+
+func samePartialFilter(filter interface{}, raw bson.Raw) bool {
+	if filter == nil || len(raw) == 0 {
+		return filter == nil && len(raw) == 0
+	}
+	var data, err = bson.Marshal(filter)
+	if err != nil {
+		return false
+	}
+
+	var m1, m2 bson.M
+	if err = bson.Unmarshal(data, &m1); err != nil {
+		return false
+	}
+	if err = bson.Unmarshal(raw, &m2); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(m1, m2)
+}
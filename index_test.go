@@ -0,0 +1,114 @@
+package dbm_test
+
+import (
+	"github.com/smartwalle/dbm"
+	"testing"
+	"time"
+)
+
+type IndexAddress struct {
+	City string `bson:"city" dbm:"index"`
+}
+
+type IndexUser struct {
+	Id        string       `bson:"_id"`
+	Name      string       `bson:"name" dbm:"index:idx_name_age,unique"`
+	Age       int          `bson:"age" dbm:"index:idx_name_age,desc;index"`
+	Address   IndexAddress `bson:"address"`
+	ExpiredAt time.Time    `bson:"expired_at" dbm:"index:idx_expired_at,ttl=60"`
+}
+
+func (IndexUser) Indexes() []dbm.Index {
+	return []dbm.Index{{Name: "idx_partial", Keys: []string{"-age"}, PartialFilter: dbm.M{"age": dbm.M{"$gt": 10}}}}
+}
+
+func TestParseIndexes(t *testing.T) {
+	var indexes, err = dbm.ParseIndexes(&IndexUser{})
+	if err != nil {
+		t.Fatal("解析索引发生错误", err)
+	}
+
+	if len(indexes) != 5 {
+		t.Fatal("索引数量不匹配", indexes)
+	}
+
+	if indexes[0].Name != "idx_name_age" || !indexes[0].Unique || len(indexes[0].Keys) != 2 || indexes[0].Keys[0] != "+name" || indexes[0].Keys[1] != "-age" {
+		t.Fatal("复合索引不匹配", indexes[0])
+	}
+
+	if indexes[1].Name != "" || indexes[1].Keys[0] != "+age" {
+		t.Fatal("索引不匹配", indexes[1])
+	}
+
+	if indexes[2].Keys[0] != "+address.city" {
+		t.Fatal("嵌套字段索引不匹配", indexes[2])
+	}
+
+	if indexes[3].ExpireAfterSeconds == nil || *indexes[3].ExpireAfterSeconds != 60 {
+		t.Fatal("TTL 索引不匹配", indexes[3])
+	}
+
+	if indexes[4].Name != "idx_partial" {
+		t.Fatal("Indexes 方法声明的索引不匹配", indexes[4])
+	}
+
+	if _, err = dbm.ParseIndexes(struct {
+		Name string `dbm:"index:a,unknown"`
+	}{}); err == nil {
+		t.Fatal("未知的索引选项应该返回错误")
+	}
+}
+
+func TestIndexSpec_KeyFields(t *testing.T) {
+	var spec = dbm.IndexSpec{
+		Keys:    dbm.D{{Key: "category", Value: int32(1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}, {Key: "location", Value: "2dsphere"}},
+		Weights: dbm.D{{Key: "title", Value: int32(1)}, {Key: "body", Value: int32(1)}},
+	}
+
+	var keys = spec.KeyFields()
+	var expected = []string{"+category", "$text:body", "$text:title", "$2dsphere:location"}
+	if len(keys) != len(expected) {
+		t.Fatal("索引 key 不匹配", keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatal("索引 key 不匹配", keys)
+		}
+	}
+}
+
+func TestIndexSpec_Match(t *testing.T) {
+	var tests = []struct {
+		spec     dbm.IndexSpec
+		keys     []string
+		expected bool
+	}{
+		// 自定义名称的索引按照 key 匹配
+		{spec: dbm.IndexSpec{Name: "idx_custom", Keys: dbm.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}}, keys: []string{"name", "-age"}, expected: true},
+		{spec: dbm.IndexSpec{Name: "idx_custom", Keys: dbm.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}}, keys: []string{"+name", "-age"}, expected: true},
+		// 名称与默认名称相同，但是 key 不一致
+		{spec: dbm.IndexSpec{Name: "name_1", Keys: dbm.D{{Key: "age", Value: int32(1)}}}, keys: []string{"name"}, expected: false},
+		// key 相同，方向不一致
+		{spec: dbm.IndexSpec{Name: "name_1_age_-1", Keys: dbm.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(1)}}}, keys: []string{"name", "-age"}, expected: false},
+		// key 的顺序不一致
+		{spec: dbm.IndexSpec{Keys: dbm.D{{Key: "age", Value: int32(-1)}, {Key: "name", Value: int32(1)}}}, keys: []string{"name", "-age"}, expected: false},
+		// 前缀索引不匹配
+		{spec: dbm.IndexSpec{Keys: dbm.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(1)}}}, keys: []string{"name"}, expected: false},
+		{spec: dbm.IndexSpec{Keys: dbm.D{{Key: "location", Value: "2dsphere"}}}, keys: []string{"$2dsphere:location"}, expected: true},
+		{
+			spec: dbm.IndexSpec{
+				Name:    "idx_text",
+				Keys:    dbm.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+				Weights: dbm.D{{Key: "title", Value: int32(1)}, {Key: "body", Value: int32(1)}},
+			},
+			keys:     []string{"$text:title", "$text:body"},
+			expected: true,
+		},
+	}
+
+	for i, test := range tests {
+		if actual := test.spec.Match(test.keys); actual != test.expected {
+			t.Fatalf("%d: %v 匹配 %v 应该为 %v", i, test.spec, test.keys, test.expected)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package dbm
+
+import (
+	"context"
+)
+
+const (
+	OpInsertOne         = "insertOne"
+	OpInsertMany        = "insertMany"
+	OpUpdateOne         = "updateOne"
+	OpUpdateMany        = "updateMany"
+	OpUpdateById        = "updateById"
+	OpReplaceOne        = "replaceOne"
+	OpDeleteOne         = "deleteOne"
+	OpDeleteMany        = "deleteMany"
+	OpFind              = "find"
+	OpFindOne           = "findOne"
+	OpCount             = "count"
+	OpFindOneAndUpdate  = "findOneAndUpdate"
+	OpFindOneAndReplace = "findOneAndReplace"
+	OpFindOneAndDelete  = "findOneAndDelete"
+	OpBulkWrite         = "bulkWrite"
+	OpDistinct          = "distinct"
+	OpAggregate         = "aggregate"
+	OpWatch             = "watch"
+	OpDrop              = "drop"
+	OpExplain           = "explain"
+)
+
+// Operation 描述一次数据库操作，拦截器可以在调用 next 之前修改其中的 Filter、Update 等字段。
+type Operation struct {
+	Name       string
+	Database   string
+	Collection string
+
+	Id       interface{} // updateById 的 _id
+	Filter   interface{}
+	Update   interface{} // update 或者 replacement
+	Document interface{} // insertOne 为单个文档，insertMany 为 []interface{}
+	Pipeline interface{}
+	Models   []WriteModel
+}
+
+type Handler func(ctx context.Context, op *Operation) error
+
+// Interceptor 拦截一次操作，需要调用 next 才会真正执行该操作，可以多次调用 next 以实现重试。
+//
+// IndexView 的操作以及 Session、事务相关的操作不会经过拦截器。
+type Interceptor func(ctx context.Context, op *Operation, next Handler) error
+
+type invoker interface {
+	invoke(ctx context.Context, op *Operation, handler Handler) error
+}
+
+func invoke(ctx context.Context, interceptors []Interceptor, op *Operation, handler Handler) error {
+	var do = handler
+	handler = func(ctx context.Context, op *Operation) error {
+		if err := checkTx(ctx); err != nil {
+			return err
+		}
+		return do(ctx, op)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		var interceptor = interceptors[i]
+		var next = handler
+		handler = func(ctx context.Context, op *Operation) error {
+			return interceptor(ctx, op, next)
+		}
+	}
+	return handler(ctx, op)
+}
+
+func appendInterceptors(interceptors []Interceptor, more ...Interceptor) []Interceptor {
+	var nInterceptors = make([]Interceptor, 0, len(interceptors)+len(more))
+	nInterceptors = append(nInterceptors, interceptors...)
+	for _, interceptor := range more {
+		if interceptor != nil {
+			nInterceptors = append(nInterceptors, interceptor)
+		}
+	}
+	return nInterceptors
+}
@@ -0,0 +1,57 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestCollection_InterceptDocuments(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("insert many", func(mt *mtest.T) {
+		var c = &collection{collection: mt.Coll, interceptors: []Interceptor{
+			func(ctx context.Context, op *Operation, next Handler) error {
+				op.Document = bson.A{bson.D{{Key: "_id", Value: 1}}}
+				return next(ctx, op)
+			},
+		}}
+
+		// 拦截器修改了 Document 的类型时不能插入空的数据
+		if _, err := c.InsertMany(context.Background(), []interface{}{bson.D{{Key: "_id", Value: 1}}}); err == nil {
+			t.Fatal("Document 的类型不是 []interface{} 时应该返回错误")
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 0 {
+			t.Fatal("不应该发送任何命令", len(events))
+		}
+	})
+
+	mt.Run("update id", func(mt *mtest.T) {
+		var names []string
+		var c = &collection{collection: mt.Coll, interceptors: []Interceptor{
+			func(ctx context.Context, op *Operation, next Handler) error {
+				names = append(names, op.Name)
+				return next(ctx, op)
+			},
+		}}
+
+		if _, err := c.UpdateId(context.Background(), nil, bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}}); !errors.Is(err, mongo.ErrNilValue) {
+			t.Fatal("_id 为 nil 时应该返回 ErrNilValue", err)
+		}
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+		var result, err = c.UpdateId(context.Background(), 1, bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}})
+		if err != nil {
+			t.Fatal("更新数据发生错误", err)
+		}
+		if result.MatchedCount != 1 {
+			t.Fatal("更新结果不匹配", result.MatchedCount)
+		}
+		if len(names) != 2 || names[0] != OpUpdateById || names[1] != OpUpdateById {
+			t.Fatal("拦截器调用记录不匹配", names)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package dbm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"reflect"
+	"strings"
+)
+
+// Page 查询第 page 页（从 1 开始）的数据，每页 size 条，同时返回不考虑 Skip 和 Limit 的总数量。
+func (q *query) Page(page, size int64, result interface{}) (int64, error) {
+	if size <= 0 {
+		return 0, ErrInvalidPageSize
+	}
+
+	var cq = *q
+	cq.skip = nil
+	cq.limit = nil
+	total, err := cq.Count()
+	if err != nil {
+		return 0, err
+	}
+
+	var skip = pageSkip(page, size)
+	var nq = *q
+	nq.skip = &skip
+	nq.limit = &size
+	if err = nq.All(result); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// PageFacet 与 Page 相同，但是通过一次 $facet 聚合同时获取数据和总数量，返回的数据总大小受限于单个文档的 16MB。
+func (q *query) PageFacet(page, size int64, result interface{}) (int64, error) {
+	if size <= 0 {
+		return 0, ErrInvalidPageSize
+	}
+
+	var ag = q.collection.Aggregate(q.ctx, q.facetPipeline(page, size))
+	if q.allowDiskUse != nil {
+		ag.AllowDiskUse(*q.allowDiskUse)
+	}
+	if q.collation != nil {
+		ag.Collation(q.collation)
+	}
+	if q.comment != nil {
+		ag.Comment(*q.comment)
+	}
+	if q.hint != nil {
+		ag.Hint(q.hint)
+	}
+	if q.maxTime != nil {
+		ag.MaxTime(*q.maxTime)
+	}
+
+	var facet pageFacet
+	if err := ag.One(&facet); err != nil {
+		return 0, err
+	}
+
+	if err := facet.Items.UnmarshalWithRegistry(q.collection.Database().Client().Registry(), result); err != nil {
+		return 0, err
+	}
+	return facet.total(), nil
+}
+
+// pageSkip 返回第 page 页需要跳过的数量，page 小于 1 时按照第 1 页处理
+func pageSkip(page, size int64) int64 {
+	if page < 1 {
+		page = 1
+	}
+	return (page - 1) * size
+}
+
+// facetPipeline 生成 PageFacet 使用的聚合管道：
+//
+//	[{$match: filter}, {$sort: sort}, {$facet: {items: [{$skip}, {$limit}, {$project}], total: [{$count: "n"}]}}]
+func (q *query) facetPipeline(page, size int64) bson.A {
+	var filter = q.filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	var items = bson.A{
+		bson.D{{Key: "$skip", Value: pageSkip(page, size)}},
+		bson.D{{Key: "$limit", Value: size}},
+	}
+	if q.projection != nil {
+		items = append(items, bson.D{{Key: "$project", Value: q.projection}})
+	}
+
+	var pipeline = bson.A{bson.D{{Key: "$match", Value: filter}}}
+	if q.sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: q.sort}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "items", Value: items},
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+	}}})
+	return pipeline
+}
+
+type pageFacet struct {
+	Items bson.RawValue `bson:"items"`
+	Total []struct {
+		N int64 `bson:"n"`
+	} `bson:"total"`
+}
+
+// total 没有匹配的数据时 $count 不会输出文档，total 为空数组
+func (facet *pageFacet) total() int64 {
+	if len(facet.Total) > 0 {
+		return facet.Total[0].N
+	}
+	return 0
+}
+
+type pageToken struct {
+	Keys   []string        `bson:"k"`
+	Values []bson.RawValue `bson:"v"`
+}
+
+// Paginate 基于 Sort 指定的字段进行 keyset 分页，after 为上一页返回的 token，第一页传空字符串。
+//
+// 排序字段中没有 _id 时会自动追加 _id 升序排序用于区分相同的值；返回的 token 为空字符串时表示没有更多数据。
+// 分页时会忽略 Skip 和 Limit，Project 需要包含所有的排序字段。
+func (q *query) Paginate(after string, size int64, result interface{}) (string, error) {
+	if size <= 0 {
+		return "", ErrInvalidPageSize
+	}
+
+	sorts, err := q.keysetSort()
+	if err != nil {
+		return "", err
+	}
+
+	var keys = keysetKeys(sorts)
+
+	var filter = q.filter
+	if after != "" {
+		token, err := decodePageToken(after, keys)
+		if err != nil {
+			return "", err
+		}
+		var rangeFilter = keysetFilter(sorts, token.Values)
+		if filter == nil {
+			filter = rangeFilter
+		} else {
+			filter = bson.D{{Key: "$and", Value: bson.A{filter, rangeFilter}}}
+		}
+	}
+
+	var limit = size + 1
+	var nq = *q
+	nq.filter = filter
+	nq.sort = sorts
+	nq.skip = nil
+	nq.limit = &limit
+
+	var raws []bson.Raw
+	if err = nq.All(&raws); err != nil {
+		return "", err
+	}
+
+	var more = int64(len(raws)) > size
+	if more {
+		raws = raws[:size]
+	}
+
+	if err = decodeRaws(q.collection.Database().Client().Registry(), raws, result); err != nil {
+		return "", err
+	}
+
+	if !more {
+		return "", nil
+	}
+	return encodePageToken(raws[len(raws)-1], sorts, keys)
+}
+
+// keysetSort 返回分页使用的排序字段，并确保包含 _id
+func (q *query) keysetSort() (bson.D, error) {
+	var sorts, _ = q.sort.(bson.D)
+	var nSorts = make(bson.D, 0, len(sorts)+1)
+	var hasId bool
+	for _, elem := range sorts {
+		if _, ok := elem.Value.(int32); !ok {
+			return nil, fmt.Errorf("dbm: paginate does not support sort by %s", elem.Key)
+		}
+		if elem.Key == "_id" {
+			hasId = true
+		}
+		nSorts = append(nSorts, elem)
+	}
+	if !hasId {
+		nSorts = append(nSorts, bson.E{Key: "_id", Value: int32(1)})
+	}
+	return nSorts, nil
+}
+
+// keysetKeys 将排序字段转换为 +field/-field 形式，用于校验 token 与当前的排序字段是否一致
+func keysetKeys(sorts bson.D) []string {
+	var keys = make([]string, 0, len(sorts))
+	for _, elem := range sorts {
+		if elem.Value.(int32) < 0 {
+			keys = append(keys, "-"+elem.Key)
+		} else {
+			keys = append(keys, "+"+elem.Key)
+		}
+	}
+	return keys
+}
+
+// keysetFilter 生成排序字段大于（降序时小于）上一页最后一条数据的查询条件：
+//
+//	{$or: [{a: {$gt: va}}, {a: va, b: {$gt: vb}}, ...]}
+func keysetFilter(sorts bson.D, values []bson.RawValue) bson.D {
+	var conditions = make(bson.A, 0, len(sorts))
+	for i, elem := range sorts {
+		var condition = make(bson.D, 0, i+1)
+		for j := 0; j < i; j++ {
+			condition = append(condition, bson.E{Key: sorts[j].Key, Value: values[j]})
+		}
+		var op = "$gt"
+		if elem.Value.(int32) < 0 {
+			op = "$lt"
+		}
+		condition = append(condition, bson.E{Key: elem.Key, Value: bson.D{{Key: op, Value: values[i]}}})
+		conditions = append(conditions, condition)
+	}
+	return bson.D{{Key: "$or", Value: conditions}}
+}
+
+func encodePageToken(raw bson.Raw, sorts bson.D, keys []string) (string, error) {
+	var token = pageToken{Keys: keys}
+	for _, elem := range sorts {
+		var value, err = raw.LookupErr(strings.Split(elem.Key, ".")...)
+		if err != nil {
+			return "", fmt.Errorf("dbm: sort field %s not found in result", elem.Key)
+		}
+		token.Values = append(token.Values, value)
+	}
+
+	var data, err = bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(s string, keys []string) (*pageToken, error) {
+	var data, err = base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var token = &pageToken{}
+	if err = bson.Unmarshal(data, token); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	// token 需要和当前的排序字段一致
+	if len(token.Keys) != len(keys) || len(token.Values) != len(keys) {
+		return nil, ErrInvalidPageToken
+	}
+	for i := range keys {
+		if token.Keys[i] != keys[i] {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	return token, nil
+}
+
+func decodeRaws(registry *bsoncodec.Registry, raws []bson.Raw, result interface{}) error {
+	var resultValue = reflect.ValueOf(result)
+	if resultValue.Kind() != reflect.Ptr || resultValue.Elem().Kind() != reflect.Slice {
+		return ErrResultNotSlice
+	}
+
+	var sliceValue = resultValue.Elem()
+	var elemType = sliceValue.Type().Elem()
+	var nSlice = reflect.MakeSlice(sliceValue.Type(), 0, len(raws))
+	for _, raw := range raws {
+		var elem = reflect.New(elemType)
+		if err := bson.UnmarshalWithRegistry(registry, raw, elem.Interface()); err != nil {
+			return err
+		}
+		nSlice = reflect.Append(nSlice, elem.Elem())
+	}
+	sliceValue.Set(nSlice)
+	return nil
+}
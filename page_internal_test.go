@@ -0,0 +1,225 @@
+package dbm
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+func TestQuery_KeysetSort(t *testing.T) {
+	var tests = []struct {
+		sorts    []string
+		expected []string
+	}{
+		// 没有 _id 时自动追加 _id 升序
+		{sorts: nil, expected: []string{"+_id"}},
+		{sorts: []string{"-age"}, expected: []string{"-age", "+_id"}},
+		{sorts: []string{"-age", "name"}, expected: []string{"-age", "+name", "+_id"}},
+		// 已经包含 _id 时保持原有的顺序和方向
+		{sorts: []string{"-_id"}, expected: []string{"-_id"}},
+		{sorts: []string{"-_id", "age"}, expected: []string{"-_id", "+age"}},
+	}
+
+	for _, test := range tests {
+		var q = &query{}
+		q.Sort(test.sorts...)
+		var sorts, err = q.keysetSort()
+		if err != nil {
+			t.Fatal("生成排序字段发生错误", err)
+		}
+		if keys := keysetKeys(sorts); !reflect.DeepEqual(keys, test.expected) {
+			t.Fatal("排序字段不匹配", test.sorts, keys)
+		}
+	}
+
+	var q = &query{sort: bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}}
+	if _, err := q.keysetSort(); err == nil {
+		t.Fatal("不支持的排序应该返回错误")
+	}
+}
+
+func TestKeysetFilter(t *testing.T) {
+	var values = []bson.RawValue{rawValue(t, 10), rawValue(t, "a"), rawValue(t, "id")}
+
+	var tests = []struct {
+		name     string
+		sorts    bson.D
+		expected bson.D
+	}{
+		{
+			name:  "asc",
+			sorts: bson.D{{Key: "_id", Value: int32(1)}},
+			expected: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: values[0]}}}},
+			}}},
+		},
+		{
+			name:  "desc",
+			sorts: bson.D{{Key: "_id", Value: int32(-1)}},
+			expected: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: values[0]}}}},
+			}}},
+		},
+		{
+			name:  "mixed",
+			sorts: bson.D{{Key: "age", Value: int32(-1)}, {Key: "name", Value: int32(1)}, {Key: "_id", Value: int32(1)}},
+			expected: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "age", Value: bson.D{{Key: "$lt", Value: values[0]}}}},
+				bson.D{{Key: "age", Value: values[0]}, {Key: "name", Value: bson.D{{Key: "$gt", Value: values[1]}}}},
+				bson.D{{Key: "age", Value: values[0]}, {Key: "name", Value: values[1]}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: values[2]}}}},
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		if actual := keysetFilter(test.sorts, values); !reflect.DeepEqual(actual, test.expected) {
+			t.Fatalf("%s: 查询条件不匹配 %v", test.name, actual)
+		}
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	var q = &query{}
+	q.Sort("-age", "address.city")
+	var sorts, err = q.keysetSort()
+	if err != nil {
+		t.Fatal("生成排序字段发生错误", err)
+	}
+	var keys = keysetKeys(sorts)
+
+	var raw, _ = bson.Marshal(bson.D{
+		{Key: "_id", Value: "u1"},
+		{Key: "age", Value: int32(18)},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Chengdu"}}},
+	})
+	token, err := encodePageToken(raw, sorts, keys)
+	if err != nil {
+		t.Fatal("生成 token 发生错误", err)
+	}
+
+	decoded, err := decodePageToken(token, keys)
+	if err != nil {
+		t.Fatal("解析 token 发生错误", err)
+	}
+	if !reflect.DeepEqual(decoded.Keys, keys) || len(decoded.Values) != 3 {
+		t.Fatal("token 不匹配", decoded)
+	}
+	if decoded.Values[0].Int32() != 18 || decoded.Values[1].StringValue() != "Chengdu" || decoded.Values[2].StringValue() != "u1" {
+		t.Fatal("token 中的值不匹配", decoded.Values)
+	}
+
+	// 结果中缺少排序字段
+	var missing, _ = bson.Marshal(bson.D{{Key: "_id", Value: "u1"}, {Key: "age", Value: int32(18)}})
+	if _, err = encodePageToken(missing, sorts, keys); err == nil {
+		t.Fatal("缺少排序字段应该返回错误")
+	}
+
+	var invalid = []struct {
+		name  string
+		token string
+		keys  []string
+	}{
+		{name: "not base64", token: "!!!", keys: keys},
+		{name: "truncated", token: token[:len(token)-4], keys: keys},
+		{name: "not bson", token: "aGVsbG8", keys: keys},
+		{name: "different direction", token: token, keys: []string{"+age", "+address.city", "+_id"}},
+		{name: "different field", token: token, keys: []string{"-age", "+name", "+_id"}},
+		{name: "fewer fields", token: token, keys: []string{"-age", "+_id"}},
+	}
+	for _, test := range invalid {
+		if _, err = decodePageToken(test.token, test.keys); !errors.Is(err, ErrInvalidPageToken) {
+			t.Fatalf("%s: 应该返回 ErrInvalidPageToken，实际为 %v", test.name, err)
+		}
+	}
+}
+
+func rawValue(t *testing.T, v interface{}) bson.RawValue {
+	var data, err = bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.Raw(data).Lookup("v")
+}
+
+func TestQuery_FacetPipeline(t *testing.T) {
+	var q = &query{filter: bson.M{"age": bson.M{"$gt": 10}}}
+	q.Sort("-age")
+	q.Project(bson.M{"name": 1})
+
+	var expected = bson.A{
+		bson.D{{Key: "$match", Value: bson.M{"age": bson.M{"$gt": 10}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "age", Value: int32(-1)}}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: bson.A{
+				bson.D{{Key: "$skip", Value: int64(20)}},
+				bson.D{{Key: "$limit", Value: int64(10)}},
+				bson.D{{Key: "$project", Value: bson.M{"name": 1}}},
+			}},
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+		}}},
+	}
+	if actual := q.facetPipeline(3, 10); !reflect.DeepEqual(actual, expected) {
+		t.Fatal("聚合管道不匹配", actual)
+	}
+
+	// 没有 filter、sort 和 projection
+	expected = bson.A{
+		bson.D{{Key: "$match", Value: bson.D{}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: bson.A{
+				bson.D{{Key: "$skip", Value: int64(0)}},
+				bson.D{{Key: "$limit", Value: int64(5)}},
+			}},
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+		}}},
+	}
+	if actual := (&query{}).facetPipeline(0, 5); !reflect.DeepEqual(actual, expected) {
+		t.Fatal("聚合管道不匹配", actual)
+	}
+}
+
+func TestPageSkip(t *testing.T) {
+	var tests = []struct {
+		page, size, expected int64
+	}{
+		{page: -1, size: 10, expected: 0},
+		{page: 0, size: 10, expected: 0},
+		{page: 1, size: 10, expected: 0},
+		{page: 2, size: 10, expected: 10},
+		{page: 5, size: 3, expected: 12},
+	}
+	for _, test := range tests {
+		if actual := pageSkip(test.page, test.size); actual != test.expected {
+			t.Fatal("跳过的数量不匹配", test.page, test.size, actual)
+		}
+	}
+
+	var q = &query{}
+	if _, err := q.Page(1, 0, nil); !errors.Is(err, ErrInvalidPageSize) {
+		t.Fatal("Page 应该返回 ErrInvalidPageSize", err)
+	}
+	if _, err := q.PageFacet(1, -1, nil); !errors.Is(err, ErrInvalidPageSize) {
+		t.Fatal("PageFacet 应该返回 ErrInvalidPageSize", err)
+	}
+}
+
+func TestPageFacet_Total(t *testing.T) {
+	var tests = []struct {
+		doc      bson.D
+		expected int64
+	}{
+		{doc: bson.D{{Key: "items", Value: bson.A{}}, {Key: "total", Value: bson.A{}}}, expected: 0},
+		{doc: bson.D{{Key: "items", Value: bson.A{bson.D{{Key: "_id", Value: 1}}}}, {Key: "total", Value: bson.A{bson.D{{Key: "n", Value: int32(42)}}}}}, expected: 42},
+	}
+	for _, test := range tests {
+		var data, _ = bson.Marshal(test.doc)
+		var facet pageFacet
+		if err := bson.Unmarshal(data, &facet); err != nil {
+			t.Fatal("解析 $facet 结果发生错误", err)
+		}
+		if actual := facet.total(); actual != test.expected {
+			t.Fatal("总数量不匹配", actual)
+		}
+	}
+}
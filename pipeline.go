@@ -0,0 +1,366 @@
+package dbm
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"strings"
+)
+
+// PipelineBuilder 用于构建聚合操作的 pipeline，可以直接传递给 Collection.Aggregate、Collection.Watch 等方法，
+// 也可以通过 Build 获取 Pipeline。
+//
+//	var pipeline = dbm.P().
+//		Match(dbm.F().Gte("age", 18)).
+//		Group("$city", dbm.AccSum("total", 1), dbm.AccAvg("age", "$age")).
+//		Sort("-total").
+//		Limit(10)
+type PipelineBuilder struct {
+	stages Pipeline
+}
+
+func P() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// Stage 添加一个 stage，可以用于添加 PipelineBuilder 没有提供的 stage
+func (p *PipelineBuilder) Stage(name string, value interface{}) *PipelineBuilder {
+	p.stages = append(p.stages, D{{Key: name, Value: value}})
+	return p
+}
+
+func (p *PipelineBuilder) Match(filter interface{}) *PipelineBuilder {
+	return p.Stage("$match", filter)
+}
+
+func (p *PipelineBuilder) Project(projection interface{}) *PipelineBuilder {
+	return p.Stage("$project", projection)
+}
+
+func (p *PipelineBuilder) AddFields(fields interface{}) *PipelineBuilder {
+	return p.Stage("$addFields", fields)
+}
+
+func (p *PipelineBuilder) ReplaceRoot(newRoot interface{}) *PipelineBuilder {
+	return p.Stage("$replaceRoot", D{{Key: "newRoot", Value: newRoot}})
+}
+
+// Group id 为分组的表达式，为 nil 时将所有文档分为一组；accumulators 由 AccSum、AccAvg 等函数生成
+func (p *PipelineBuilder) Group(id interface{}, accumulators ...E) *PipelineBuilder {
+	var group = D{{Key: "_id", Value: id}}
+	group = append(group, accumulators...)
+	return p.Stage("$group", group)
+}
+
+// Sort 使用与 Query.Sort 相同的格式，如 Sort("-age", "+name")
+func (p *PipelineBuilder) Sort(fields ...string) *PipelineBuilder {
+	return p.Stage("$sort", sortFields(fields...))
+}
+
+func (p *PipelineBuilder) Skip(n int64) *PipelineBuilder {
+	return p.Stage("$skip", n)
+}
+
+func (p *PipelineBuilder) Limit(n int64) *PipelineBuilder {
+	return p.Stage("$limit", n)
+}
+
+func (p *PipelineBuilder) Sample(size int64) *PipelineBuilder {
+	return p.Stage("$sample", D{{Key: "size", Value: size}})
+}
+
+// Count 统计文档数量，结果保存在字段 field 中
+func (p *PipelineBuilder) Count(field string) *PipelineBuilder {
+	return p.Stage("$count", field)
+}
+
+func (p *PipelineBuilder) Lookup(from, localField, foreignField, as string) *PipelineBuilder {
+	return p.Stage("$lookup", D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	})
+}
+
+// LookupPipeline 使用 pipeline 关联集合 from，let 为 pipeline 中可以使用的变量，可以为 nil
+func (p *PipelineBuilder) LookupPipeline(from string, let interface{}, pipeline interface{}, as string) *PipelineBuilder {
+	var lookup = D{{Key: "from", Value: from}}
+	if let != nil {
+		lookup = append(lookup, E{Key: "let", Value: let})
+	}
+	lookup = append(lookup, E{Key: "pipeline", Value: pipeline}, E{Key: "as", Value: as})
+	return p.Stage("$lookup", lookup)
+}
+
+// Unwind path 需要以 $ 开头，preserveNullAndEmptyArrays 为 true 时保留数组为空或者不存在的文档
+func (p *PipelineBuilder) Unwind(path string, preserveNullAndEmptyArrays bool) *PipelineBuilder {
+	if !preserveNullAndEmptyArrays {
+		return p.Stage("$unwind", path)
+	}
+	return p.Stage("$unwind", D{{Key: "path", Value: path}, {Key: "preserveNullAndEmptyArrays", Value: true}})
+}
+
+// Facet facets 中每个元素的 Key 为输出字段，Value 为对应的 pipeline
+func (p *PipelineBuilder) Facet(facets ...E) *PipelineBuilder {
+	return p.Stage("$facet", D(facets))
+}
+
+// Bucket defaultBucket 为 nil 时不设置 default，output 由 AccSum、AccAvg 等函数生成
+func (p *PipelineBuilder) Bucket(groupBy interface{}, boundaries interface{}, defaultBucket interface{}, output ...E) *PipelineBuilder {
+	var bucket = D{{Key: "groupBy", Value: groupBy}, {Key: "boundaries", Value: boundaries}}
+	if defaultBucket != nil {
+		bucket = append(bucket, E{Key: "default", Value: defaultBucket})
+	}
+	if len(output) > 0 {
+		bucket = append(bucket, E{Key: "output", Value: D(output)})
+	}
+	return p.Stage("$bucket", bucket)
+}
+
+func (p *PipelineBuilder) BucketAuto(groupBy interface{}, buckets int, output ...E) *PipelineBuilder {
+	var bucket = D{{Key: "groupBy", Value: groupBy}, {Key: "buckets", Value: buckets}}
+	if len(output) > 0 {
+		bucket = append(bucket, E{Key: "output", Value: D(output)})
+	}
+	return p.Stage("$bucketAuto", bucket)
+}
+
+// SetWindowFields partitionBy 可以为 nil，sortBy 使用与 Query.Sort 相同的格式，output 中每个元素的 Value 为窗口函数及 window 定义
+func (p *PipelineBuilder) SetWindowFields(partitionBy interface{}, sortBy []string, output ...E) *PipelineBuilder {
+	var fields D
+	if partitionBy != nil {
+		fields = append(fields, E{Key: "partitionBy", Value: partitionBy})
+	}
+	if len(sortBy) > 0 {
+		fields = append(fields, E{Key: "sortBy", Value: sortFields(sortBy...)})
+	}
+	fields = append(fields, E{Key: "output", Value: D(output)})
+	return p.Stage("$setWindowFields", fields)
+}
+
+// UnionWith pipeline 可以为 nil
+func (p *PipelineBuilder) UnionWith(collection string, pipeline interface{}) *PipelineBuilder {
+	if pipeline == nil {
+		return p.Stage("$unionWith", collection)
+	}
+	return p.Stage("$unionWith", D{{Key: "coll", Value: collection}, {Key: "pipeline", Value: pipeline}})
+}
+
+func (p *PipelineBuilder) Out(collection string) *PipelineBuilder {
+	return p.Stage("$out", collection)
+}
+
+// Merge opts 为 on、whenMatched、whenNotMatched 等选项，如 dbm.NE("whenMatched", "merge")
+func (p *PipelineBuilder) Merge(into string, opts ...E) *PipelineBuilder {
+	var merge = D{{Key: "into", Value: into}}
+	merge = append(merge, opts...)
+	return p.Stage("$merge", merge)
+}
+
+// Validate 检查 pipeline 中明显的错误，如 $group 缺少 _id、$out 不是最后一个 stage 等
+func (p *PipelineBuilder) Validate() error {
+	return validatePipeline(p.stages, false)
+}
+
+// Build 检查并返回 Pipeline
+func (p *PipelineBuilder) Build() (Pipeline, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	var stages = make(Pipeline, len(p.stages))
+	copy(stages, p.stages)
+	return stages, nil
+}
+
+// MarshalBSONValue 实现 bsoncodec.ValueMarshaler 接口，编码之前会检查 pipeline
+func (p *PipelineBuilder) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	var stages, err = p.Build()
+	if err != nil {
+		return 0, nil, err
+	}
+	if stages == nil {
+		stages = Pipeline{}
+	}
+	return bson.MarshalValue(stages)
+}
+
+func validatePipeline(stages Pipeline, inFacet bool) error {
+	for i, stage := range stages {
+		if len(stage) != 1 {
+			return fmt.Errorf("dbm: stage %d must have exactly one field", i)
+		}
+
+		var name, value = stage[0].Key, stage[0].Value
+		if !strings.HasPrefix(name, "$") {
+			return fmt.Errorf("dbm: stage %d: invalid stage name %q", i, name)
+		}
+
+		var fields, isDoc = stageFields(value)
+		var err error
+		switch name {
+		case "$group":
+			if isDoc {
+				err = validateGroup(fields)
+			}
+		case "$lookup":
+			if s, _ := lookupValue(fields, "from").(string); isDoc && s == "" {
+				err = errors.New("from must not be empty")
+			} else if s, _ = lookupValue(fields, "as").(string); isDoc && s == "" {
+				err = errors.New("as must not be empty")
+			}
+		case "$unwind":
+			var path, _ = value.(string)
+			if isDoc {
+				path, _ = lookupValue(fields, "path").(string)
+			}
+			if !strings.HasPrefix(path, "$") {
+				err = fmt.Errorf("path %q must start with $", path)
+			}
+		case "$limit", "$sample":
+			if isDoc {
+				value = lookupValue(fields, "size")
+			}
+			if n, ok := intValue(value); ok && n <= 0 {
+				err = errors.New("value must be greater than 0")
+			}
+		case "$skip":
+			if n, ok := intValue(value); ok && n < 0 {
+				err = errors.New("value must not be negative")
+			}
+		case "$count":
+			if field, _ := value.(string); field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+				err = fmt.Errorf("invalid field %q", field)
+			}
+		case "$out", "$merge":
+			if inFacet {
+				err = errors.New("can not be used in $facet")
+			} else if i != len(stages)-1 {
+				err = errors.New("must be the last stage")
+			}
+		case "$facet":
+			if inFacet {
+				err = errors.New("can not be used in $facet")
+				break
+			}
+			for _, facet := range fields {
+				var sub Pipeline
+				switch pipeline := facet.Value.(type) {
+				case *PipelineBuilder:
+					sub = pipeline.stages
+				case Pipeline:
+					sub = pipeline
+				}
+				if err = validatePipeline(sub, true); err != nil {
+					err = fmt.Errorf("%s: %w", facet.Key, err)
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("dbm: stage %d %s: %w", i, name, err)
+		}
+	}
+	return nil
+}
+
+func validateGroup(group D) error {
+	var hasId bool
+	for _, field := range group {
+		if field.Key == "_id" {
+			if id, ok := field.Value.(string); ok && id == "" {
+				return errors.New("_id must not be empty")
+			}
+			hasId = true
+			continue
+		}
+		if field.Key == "" || strings.Contains(field.Key, ".") {
+			return fmt.Errorf("invalid field %q", field.Key)
+		}
+		if accumulator, ok := stageFields(field.Value); !ok || len(accumulator) != 1 || !strings.HasPrefix(accumulator[0].Key, "$") {
+			return fmt.Errorf("field %s must be an accumulator", field.Key)
+		}
+	}
+	if !hasId {
+		return errors.New("_id is required")
+	}
+	return nil
+}
+
+// stageFields 将 D 或者 M 转换为 D，其它类型返回 false
+func stageFields(value interface{}) (D, bool) {
+	switch v := value.(type) {
+	case D:
+		return v, true
+	case M:
+		var d = make(D, 0, len(v))
+		for key, value := range v {
+			d = append(d, E{Key: key, Value: value})
+		}
+		return d, true
+	}
+	return nil, false
+}
+
+func lookupValue(d D, key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+func intValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+func accumulator(field, op string, expr interface{}) E {
+	return E{Key: field, Value: D{{Key: op, Value: expr}}}
+}
+
+func AccSum(field string, expr interface{}) E {
+	return accumulator(field, "$sum", expr)
+}
+
+func AccAvg(field string, expr interface{}) E {
+	return accumulator(field, "$avg", expr)
+}
+
+func AccMin(field string, expr interface{}) E {
+	return accumulator(field, "$min", expr)
+}
+
+func AccMax(field string, expr interface{}) E {
+	return accumulator(field, "$max", expr)
+}
+
+func AccFirst(field string, expr interface{}) E {
+	return accumulator(field, "$first", expr)
+}
+
+func AccLast(field string, expr interface{}) E {
+	return accumulator(field, "$last", expr)
+}
+
+func AccPush(field string, expr interface{}) E {
+	return accumulator(field, "$push", expr)
+}
+
+func AccAddToSet(field string, expr interface{}) E {
+	return accumulator(field, "$addToSet", expr)
+}
+
+// AccCount 统计文档数量，等同于 {$sum: 1}
+func AccCount(field string) E {
+	return accumulator(field, "$sum", 1)
+}
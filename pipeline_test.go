@@ -0,0 +1,58 @@
+package dbm_test
+
+import (
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestPipelineBuilder(t *testing.T) {
+	var pipeline = dbm.P().
+		Match(dbm.F().Gte("age", 18)).
+		Lookup("order", "_id", "user_id", "orders").
+		Unwind("$orders", true).
+		Group("$city", dbm.AccSum("total", "$orders.amount"), dbm.AccCount("count")).
+		Sort("-total").
+		Limit(10).
+		Facet(dbm.NE("top", dbm.P().Limit(3)), dbm.NE("count", dbm.P().Count("n")))
+
+	var data, err = bson.MarshalExtJSON(bson.D{{Key: "pipeline", Value: pipeline}}, false, false)
+	if err != nil {
+		t.Fatal("转换 pipeline 发生错误", err)
+	}
+
+	var expected = `{"pipeline":[` +
+		`{"$match":{"age":{"$gte":18}}},` +
+		`{"$lookup":{"from":"order","localField":"_id","foreignField":"user_id","as":"orders"}},` +
+		`{"$unwind":{"path":"$orders","preserveNullAndEmptyArrays":true}},` +
+		`{"$group":{"_id":"$city","total":{"$sum":"$orders.amount"},"count":{"$sum":1}}},` +
+		`{"$sort":{"total":-1}},` +
+		`{"$limit":10},` +
+		`{"$facet":{"top":[{"$limit":3}],"count":[{"$count":"n"}]}}]}`
+	if string(data) != expected {
+		t.Fatalf("期望 %s，实际 %s", expected, string(data))
+	}
+}
+
+func TestPipelineBuilder_Validate(t *testing.T) {
+	var tests = []*dbm.PipelineBuilder{
+		dbm.P().Group(""),
+		dbm.P().Stage("$group", dbm.M{"total": dbm.M{"$sum": 1}}),
+		dbm.P().Group("$city", dbm.NE("total", 1)),
+		dbm.P().Out("result").Match(dbm.M{}),
+		dbm.P().Unwind("tags", false),
+		dbm.P().Limit(0),
+		dbm.P().Facet(dbm.NE("items", dbm.P().Out("result"))),
+		dbm.P().Lookup("", "_id", "user_id", "orders"),
+	}
+
+	for i, test := range tests {
+		if err := test.Validate(); err == nil {
+			t.Fatalf("第 %d 个 pipeline 应该检查失败", i)
+		}
+	}
+
+	if _, err := dbm.P().Match(dbm.M{}).Group(nil, dbm.AccCount("n")).Merge("result").Build(); err != nil {
+		t.Fatal("pipeline 应该检查通过", err)
+	}
+}
@@ -3,6 +3,7 @@ package dbm
 import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"strings"
 	"time"
@@ -81,6 +82,19 @@ type Query interface {
 
 	Count() (int64, error)
 
+	Page(page, size int64, result interface{}) (int64, error)
+
+	PageFacet(page, size int64, result interface{}) (int64, error)
+
+	Paginate(after string, size int64, result interface{}) (string, error)
+
+	// EachBatch 每次读取最多 n 个文档并调用 fn，batch 的底层数组会被复用，fn 中不能保留 batch。
+	//
+	// fn 返回错误或者 ctx 被取消时停止读取并返回该错误；需要将文档解析为结构体时使用 TypedQuery.EachBatch 或者 CursorOf.EachBatch。
+	EachBatch(n int, fn func(batch []bson.Raw) error) error
+
+	Explain(verbosity ExplainVerbosity) (*ExplainResult, error)
+
 	Cursor() Cursor
 }
 
@@ -107,7 +121,7 @@ type query struct {
 	sort                interface{}
 
 	ctx        context.Context
-	collection Collection
+	collection *collection
 }
 
 func (q *query) AllowDiskUse(b bool) Query {
@@ -298,7 +312,10 @@ func (q *query) One(result interface{}) error {
 		opts.SetSort(q.sort)
 	}
 
-	return q.collection.Collection().FindOne(q.ctx, q.filter, opts).Decode(result)
+	var op = &Operation{Name: OpFindOne, Filter: q.filter}
+	return q.collection.invoke(q.ctx, op, func(ctx context.Context, op *Operation) error {
+		return q.collection.collection.FindOne(ctx, op.Filter, opts).Decode(result)
+	})
 }
 
 func (q *query) All(result interface{}) error {
@@ -307,6 +324,10 @@ func (q *query) All(result interface{}) error {
 	return cur.All(q.ctx, result)
 }
 
+func (q *query) EachBatch(n int, fn func(batch []bson.Raw) error) error {
+	return NewCursorOf[bson.Raw](q.Cursor()).EachBatch(q.ctx, n, fn)
+}
+
 func (q *query) Count() (n int64, err error) {
 	var opts = options.Count()
 
@@ -326,10 +347,27 @@ func (q *query) Count() (n int64, err error) {
 		opts.SetSkip(*q.skip)
 	}
 
-	return q.collection.Collection().CountDocuments(q.ctx, q.filter, opts)
+	var op = &Operation{Name: OpCount, Filter: q.filter}
+	err = q.collection.invoke(q.ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		n, err = q.collection.collection.CountDocuments(ctx, op.Filter, opts)
+		return err
+	})
+	return n, err
 }
 
 func (q *query) Cursor() Cursor {
+	var opts = q.findOptions()
+
+	var cur *mongo.Cursor
+	var op = &Operation{Name: OpFind, Filter: q.filter}
+	var err = q.collection.invoke(q.ctx, op, func(ctx context.Context, op *Operation) (err error) {
+		cur, err = q.collection.collection.Find(ctx, op.Filter, opts)
+		return err
+	})
+	return &cursor{Cursor: cur, err: err}
+}
+
+func (q *query) findOptions() *options.FindOptions {
 	var opts = options.Find()
 
 	if q.allowDiskUse != nil {
@@ -386,9 +424,7 @@ func (q *query) Cursor() Cursor {
 	if q.sort != nil {
 		opts.SetSort(q.sort)
 	}
-
-	var cur, err = q.collection.Collection().Find(q.ctx, q.filter, opts)
-	return &cursor{Cursor: cur, err: err}
+	return opts
 }
 
 type FindUpdate interface {
@@ -419,7 +455,7 @@ type findUpdate struct {
 
 	ctx        context.Context
 	opts       *options.FindOneAndUpdateOptions
-	collection Collection
+	collection *collection
 }
 
 func (fu *findUpdate) ArrayFilters(filters ArrayFilters) FindUpdate {
@@ -471,8 +507,10 @@ func (fu *findUpdate) Hint(hint interface{}) FindUpdate {
 }
 
 func (fu *findUpdate) Apply(result interface{}) error {
-	var err = fu.collection.Collection().FindOneAndUpdate(fu.ctx, fu.filter, fu.update, fu.opts).Decode(result)
-	return err
+	var op = &Operation{Name: OpFindOneAndUpdate, Filter: fu.filter, Update: fu.update}
+	return fu.collection.invoke(fu.ctx, op, func(ctx context.Context, op *Operation) error {
+		return fu.collection.collection.FindOneAndUpdate(ctx, op.Filter, op.Update, fu.opts).Decode(result)
+	})
 }
 
 type FindReplace interface {
@@ -501,7 +539,7 @@ type findReplace struct {
 
 	ctx        context.Context
 	opts       *options.FindOneAndReplaceOptions
-	collection Collection
+	collection *collection
 }
 
 func (fr *findReplace) BypassDocumentValidation(b bool) FindReplace {
@@ -548,8 +586,10 @@ func (fr *findReplace) Hint(hint interface{}) FindReplace {
 }
 
 func (fr *findReplace) Apply(result interface{}) error {
-	var err = fr.collection.Collection().FindOneAndReplace(fr.ctx, fr.filter, fr.replacement, fr.opts).Decode(result)
-	return err
+	var op = &Operation{Name: OpFindOneAndReplace, Filter: fr.filter, Update: fr.replacement}
+	return fr.collection.invoke(fr.ctx, op, func(ctx context.Context, op *Operation) error {
+		return fr.collection.collection.FindOneAndReplace(ctx, op.Filter, op.Update, fr.opts).Decode(result)
+	})
 }
 
 type FindDelete interface {
@@ -571,7 +611,7 @@ type findDelete struct {
 
 	ctx        context.Context
 	opts       *options.FindOneAndDeleteOptions
-	collection Collection
+	collection *collection
 }
 
 func (fd *findDelete) Collation(c *Collation) FindDelete {
@@ -603,6 +643,8 @@ func (fd *findDelete) Hint(hint interface{}) FindDelete {
 }
 
 func (fd *findDelete) Apply(result interface{}) error {
-	var err = fd.collection.Collection().FindOneAndDelete(fd.ctx, fd.filter, fd.opts).Decode(result)
-	return err
+	var op = &Operation{Name: OpFindOneAndDelete, Filter: fd.filter}
+	return fd.collection.invoke(fd.ctx, op, func(ctx context.Context, op *Operation) error {
+		return fd.collection.collection.FindOneAndDelete(ctx, op.Filter, fd.opts).Decode(result)
+	})
 }
@@ -10,4 +10,53 @@ type DeleteResult = mongo.DeleteResult
 
 type UpdateResult = mongo.UpdateResult
 
-type BulkResult = mongo.BulkWriteResult
+type BulkOperationStatus string
+
+const (
+	BulkSucceeded BulkOperationStatus = "succeeded"
+	BulkFailed    BulkOperationStatus = "failed"
+
+	// BulkSkipped 有序执行时，前面的操作失败导致该操作没有被执行
+	BulkSkipped BulkOperationStatus = "skipped"
+
+	// BulkUnknown 发生网络错误等情况时，无法确定该操作是否被执行
+	BulkUnknown BulkOperationStatus = "unknown"
+)
+
+// BulkOperationResult 为 Bulk 中单个操作的执行结果。
+//
+// mongodb 只返回所有操作的 matched、modified 等统计数量，没有单个操作的数量。
+type BulkOperationResult struct {
+	// Index 为该操作在 Bulk 中的下标
+	Index int
+
+	// Tag 为通过 Bulk.Tag 设置的标识
+	Tag interface{}
+
+	Model  WriteModel
+	Status BulkOperationStatus
+
+	// UpsertedID 为 upsert 操作插入的文档的 _id
+	UpsertedID interface{}
+
+	// Err 为该操作的错误，唯一索引冲突时为 *DuplicateKeyError，其它情况为 mongo.BulkWriteError
+	Err error
+}
+
+type BulkResult struct {
+	mongo.BulkWriteResult
+
+	// Operations 为每个操作的执行结果，顺序与添加操作的顺序一致
+	Operations []BulkOperationResult
+}
+
+// Failed 返回执行失败的操作
+func (r *BulkResult) Failed() []BulkOperationResult {
+	var failed []BulkOperationResult
+	for _, op := range r.Operations {
+		if op.Status == BulkFailed {
+			failed = append(failed, op)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,348 @@
+package dbm
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"sync"
+)
+
+// ScanRange 描述 ParallelScan 中的一个 _id 区间 [Min, Max) 及其处理进度。
+//
+// 处理失败的区间可以通过 Collection.ResumeScan 从 LastId 之后继续处理。
+type ScanRange struct {
+	Index int
+
+	// Min 为区间的最小值（包含），为 nil 时表示没有下限
+	Min interface{}
+
+	// Max 为区间的最大值（不包含），为 nil 时表示没有上限
+	Max interface{}
+
+	// ExcludeType 不为空时区间只包含 _id 类型不是 ExcludeType 的文档，用于处理采样时没有发现的 _id 类型，此时 Min 和 Max 总是为 nil
+	ExcludeType string
+
+	// LastId 为最后一个处理成功的文档的 _id
+	LastId interface{}
+
+	// Count 为处理成功的文档数量
+	Count int64
+
+	Done bool
+	Err  error
+}
+
+// ScanHandler 处理 ParallelScan 中的一个文档，可以通过 cur.One 解析文档；多个区间会同时调用 ScanHandler。
+type ScanHandler func(ctx context.Context, cur Cursor) error
+
+type ParallelScanOptions struct {
+	// Ranges 为拆分的区间数量，默认为 workers 的 4 倍
+	Ranges int
+
+	// SampleSize 为通过 $sample 计算区间边界时采样的文档数量，默认为 Ranges 的 20 倍
+	SampleSize int
+
+	BatchSize int32
+
+	// Projection 不能排除 _id，否则返回 ErrScanWithoutId
+	Projection interface{}
+}
+
+func NewParallelScanOptions() *ParallelScanOptions {
+	return &ParallelScanOptions{}
+}
+
+func (opts *ParallelScanOptions) SetRanges(n int) *ParallelScanOptions {
+	opts.Ranges = n
+	return opts
+}
+
+func (opts *ParallelScanOptions) SetSampleSize(n int) *ParallelScanOptions {
+	opts.SampleSize = n
+	return opts
+}
+
+func (opts *ParallelScanOptions) SetBatchSize(n int32) *ParallelScanOptions {
+	opts.BatchSize = n
+	return opts
+}
+
+func (opts *ParallelScanOptions) SetProjection(projection interface{}) *ParallelScanOptions {
+	opts.Projection = projection
+	return opts
+}
+
+func mergeParallelScanOptions(opts ...*ParallelScanOptions) *ParallelScanOptions {
+	var nOpts = NewParallelScanOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Ranges > 0 {
+			nOpts.Ranges = opt.Ranges
+		}
+		if opt.SampleSize > 0 {
+			nOpts.SampleSize = opt.SampleSize
+		}
+		if opt.BatchSize > 0 {
+			nOpts.BatchSize = opt.BatchSize
+		}
+		if opt.Projection != nil {
+			nOpts.Projection = opt.Projection
+		}
+	}
+	return nOpts
+}
+
+// ParallelScan 将满足 filter 的文档按照 _id 拆分为多个互不相交的区间，使用 workers 个 goroutine 同时处理。
+//
+// 区间的边界通过 $sample 采样计算，由于查询时 $gte 和 $lt 只会匹配相同类型的值，_id 类型与采样结果不同的文档由最后一个额外的区间处理。
+// 某个区间处理失败时不会影响其它区间，返回的错误为第一个失败的区间的错误，可以通过返回的 ScanRange 重试失败的区间。
+func (c *collection) ParallelScan(ctx context.Context, filter interface{}, workers int, fn ScanHandler, opts ...*ParallelScanOptions) ([]*ScanRange, error) {
+	if workers <= 0 {
+		workers = 1
+	}
+	var nOpts = mergeParallelScanOptions(opts...)
+	if nOpts.Ranges <= 0 {
+		nOpts.Ranges = workers * 4
+	}
+	if nOpts.SampleSize <= 0 {
+		nOpts.SampleSize = nOpts.Ranges * 20
+	}
+	if err := checkScanProjection(nOpts.Projection); err != nil {
+		return nil, err
+	}
+
+	var ranges, err = c.splitScanRanges(ctx, filter, nOpts.Ranges, nOpts.SampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue = make(chan *ScanRange)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range queue {
+				c.ResumeScan(ctx, filter, r, fn, nOpts)
+			}
+		}()
+	}
+	var sent int
+send:
+	for ; sent < len(ranges); sent++ {
+		select {
+		case queue <- ranges[sent]:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	// ctx 被取消之后没有处理的区间
+	for _, r := range ranges[sent:] {
+		r.Err = ctx.Err()
+	}
+
+	for _, r := range ranges {
+		if r.Err != nil {
+			return ranges, fmt.Errorf("dbm: scan range %d: %w", r.Index, r.Err)
+		}
+	}
+	return ranges, nil
+}
+
+// ResumeScan 处理区间 r 中 LastId 之后的文档，并更新 r 的进度。
+func (c *collection) ResumeScan(ctx context.Context, filter interface{}, r *ScanRange, fn ScanHandler, opts ...*ParallelScanOptions) error {
+	var nOpts = mergeParallelScanOptions(opts...)
+	r.Err = nil
+	r.Done = false
+	if r.Err = checkScanProjection(nOpts.Projection); r.Err != nil {
+		return r.Err
+	}
+
+	var nFilter = scanFilter(filter, r)
+	var q = c.Find(ctx, nFilter).Sort("_id")
+	if nOpts.BatchSize > 0 {
+		q.BatchSize(nOpts.BatchSize)
+	}
+	if nOpts.Projection != nil {
+		q.Project(nOpts.Projection)
+	}
+
+	var cur = q.Cursor()
+	defer cur.Close(context.Background())
+
+	for cur.Next(ctx) {
+		var doc struct {
+			Id bson.RawValue `bson:"_id"`
+		}
+		if r.Err = cur.One(&doc); r.Err != nil {
+			return r.Err
+		}
+		if doc.Id.Type == 0 {
+			r.Err = ErrScanWithoutId
+			return r.Err
+		}
+		if r.Err = fn(ctx, cur); r.Err != nil {
+			return r.Err
+		}
+		r.LastId = doc.Id
+		r.Count++
+	}
+	if r.Err = cur.Error(); r.Err != nil {
+		return r.Err
+	}
+	r.Done = true
+	return nil
+}
+
+// checkScanProjection 检查 projection 是否排除了 _id，ResumeScan 需要通过 _id 记录处理进度
+func checkScanProjection(projection interface{}) error {
+	if projection == nil {
+		return nil
+	}
+	var fields, ok = updateFields(projection)
+	if !ok {
+		return nil
+	}
+	for _, field := range fields {
+		if field.Key != "_id" {
+			continue
+		}
+		switch v := field.Value.(type) {
+		case bool:
+			ok = v
+		case int:
+			ok = v != 0
+		case int32:
+			ok = v != 0
+		case int64:
+			ok = v != 0
+		case float64:
+			ok = v != 0
+		}
+		if !ok {
+			return ErrScanWithoutId
+		}
+	}
+	return nil
+}
+
+// scanFilter 返回区间 r 中 LastId 之后的文档的查询条件
+func scanFilter(filter interface{}, r *ScanRange) interface{} {
+	var conditions = A{}
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+
+	var idRange = D{}
+	if r.ExcludeType != "" {
+		idRange = append(idRange, E{Key: "$not", Value: D{{Key: "$type", Value: r.ExcludeType}}})
+	}
+	if r.LastId != nil && r.Min == nil && r.Max == nil {
+		// 没有边界的区间中可能有多种类型的 _id，$gt 只会匹配与 LastId 类型相同的值，所以使用 $expr 按照 BSON 的顺序比较
+		conditions = append(conditions, D{{Key: "$expr", Value: D{{Key: "$gt", Value: A{"$_id", r.LastId}}}}})
+	} else if r.LastId != nil {
+		idRange = append(idRange, E{Key: "$gt", Value: r.LastId})
+	} else if r.Min != nil {
+		idRange = append(idRange, E{Key: "$gte", Value: r.Min})
+	}
+	if r.Max != nil {
+		idRange = append(idRange, E{Key: "$lt", Value: r.Max})
+	}
+	if len(idRange) > 0 {
+		conditions = append(conditions, D{{Key: "_id", Value: idRange}})
+	}
+
+	switch len(conditions) {
+	case 0:
+		return D{}
+	case 1:
+		return conditions[0]
+	}
+	return D{{Key: "$and", Value: conditions}}
+}
+
+// splitScanRanges 通过 $sample 采样计算 n 个区间的边界
+func (c *collection) splitScanRanges(ctx context.Context, filter interface{}, n, sampleSize int) ([]*ScanRange, error) {
+	if filter == nil {
+		filter = D{}
+	}
+
+	var ids []bson.RawValue
+	if n > 1 {
+		var pipeline = P().Match(filter).Sample(int64(sampleSize)).Project(D{{Key: "_id", Value: 1}}).Sort("_id")
+		var docs []struct {
+			Id bson.RawValue `bson:"_id"`
+		}
+		if err := c.Aggregate(ctx, pipeline).All(&docs); err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			ids = append(ids, doc.Id)
+		}
+	}
+	return scanRanges(ids, n), nil
+}
+
+// scanRanges 根据排序之后的采样结果计算 n 个区间，采样结果中 _id 的类型不一致时只返回一个没有边界的区间；
+// 拆分区间时额外返回一个处理其它类型的 _id 的区间
+func scanRanges(ids []bson.RawValue, n int) []*ScanRange {
+	var alias string
+	for i, id := range ids {
+		var nAlias = scanTypeAlias(id.Type)
+		if nAlias == "" || (i > 0 && nAlias != alias) {
+			ids = nil
+			break
+		}
+		alias = nAlias
+	}
+
+	var bounds []bson.RawValue
+	for i := 1; i < n && len(ids) > 0; i++ {
+		var bound = ids[i*len(ids)/n]
+		if len(bounds) > 0 && bounds[len(bounds)-1].Equal(bound) {
+			continue
+		}
+		bounds = append(bounds, bound)
+	}
+	if len(bounds) == 0 {
+		return []*ScanRange{{Index: 0}}
+	}
+
+	var ranges = make([]*ScanRange, 0, len(bounds)+2)
+	var min interface{}
+	for _, bound := range bounds {
+		ranges = append(ranges, &ScanRange{Index: len(ranges), Min: min, Max: bound})
+		min = bound
+	}
+	ranges = append(ranges, &ScanRange{Index: len(ranges), Min: min})
+	ranges = append(ranges, &ScanRange{Index: len(ranges), ExcludeType: alias})
+	return ranges
+}
+
+// scanTypeAlias 返回 _id 类型在 $type 中的别名，数字类型之间可以互相比较，所以统一为 number；
+// 不支持的类型返回空字符串，此时不会拆分区间
+func scanTypeAlias(t bsontype.Type) string {
+	switch t {
+	case bsontype.Double, bsontype.Int32, bsontype.Int64, bsontype.Decimal128:
+		return "number"
+	case bsontype.String:
+		return "string"
+	case bsontype.ObjectID:
+		return "objectId"
+	case bsontype.DateTime:
+		return "date"
+	case bsontype.Binary:
+		return "binData"
+	case bsontype.Boolean:
+		return "bool"
+	case bsontype.Timestamp:
+		return "timestamp"
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"reflect"
+	"testing"
+)
+
+func TestScanRanges(t *testing.T) {
+	var ids = make([]bson.RawValue, 0, 10)
+	for i := 0; i < 10; i++ {
+		ids = append(ids, rawValue(t, int32(i)))
+	}
+
+	var ranges = scanRanges(ids, 3)
+	if len(ranges) != 4 {
+		t.Fatal("区间数量不匹配", len(ranges))
+	}
+	if ranges[0].Min != nil || !ranges[0].Max.(bson.RawValue).Equal(ids[3]) {
+		t.Fatal("第一个区间不匹配", ranges[0])
+	}
+	if !ranges[1].Min.(bson.RawValue).Equal(ids[3]) || !ranges[1].Max.(bson.RawValue).Equal(ids[6]) {
+		t.Fatal("中间的区间不匹配", ranges[1])
+	}
+	if !ranges[2].Min.(bson.RawValue).Equal(ids[6]) || ranges[2].Max != nil {
+		t.Fatal("最后一个区间不匹配", ranges[2])
+	}
+	// 额外的区间处理采样时没有发现的 _id 类型
+	if ranges[3].Min != nil || ranges[3].Max != nil || ranges[3].ExcludeType != "number" {
+		t.Fatal("处理其它类型的区间不匹配", ranges[3])
+	}
+	for i, r := range ranges {
+		if r.Index != i {
+			t.Fatal("区间的序号不匹配", i, r.Index)
+		}
+	}
+
+	// int32 和 int64 可以互相比较，作为同一种类型
+	ranges = scanRanges([]bson.RawValue{rawValue(t, int32(1)), rawValue(t, int64(2)), rawValue(t, 3.5)}, 2)
+	if len(ranges) != 3 || ranges[2].ExcludeType != "number" {
+		t.Fatal("数字类型的区间不匹配", ranges)
+	}
+
+	// 相同的边界只保留一个
+	ranges = scanRanges([]bson.RawValue{rawValue(t, "a"), rawValue(t, "a"), rawValue(t, "a")}, 3)
+	if len(ranges) != 3 || ranges[2].ExcludeType != "string" {
+		t.Fatal("相同边界的区间不匹配", ranges)
+	}
+
+	var tests = []struct {
+		name string
+		ids  []bson.RawValue
+		n    int
+	}{
+		{name: "no sample", ids: nil, n: 4},
+		{name: "single range", ids: ids, n: 1},
+		{name: "mixed types", ids: []bson.RawValue{rawValue(t, int32(1)), rawValue(t, "a"), rawValue(t, primitive.NewObjectID())}, n: 2},
+		{name: "unsupported type", ids: []bson.RawValue{rawValue(t, bson.D{{Key: "a", Value: 1}}), rawValue(t, bson.D{{Key: "b", Value: 1}})}, n: 2},
+	}
+	for _, test := range tests {
+		ranges = scanRanges(test.ids, test.n)
+		if len(ranges) != 1 || ranges[0].Min != nil || ranges[0].Max != nil || ranges[0].ExcludeType != "" {
+			t.Fatalf("%s: 应该只返回一个没有边界的区间 %v", test.name, ranges)
+		}
+	}
+}
+
+func TestScanFilter(t *testing.T) {
+	var min, max, last = rawValue(t, "a"), rawValue(t, "m"), rawValue(t, "c")
+	var filter = bson.M{"name": "x"}
+
+	var tests = []struct {
+		name     string
+		filter   interface{}
+		r        *ScanRange
+		expected interface{}
+	}{
+		{
+			name:     "full",
+			r:        &ScanRange{},
+			expected: D{},
+		},
+		{
+			name:     "full with filter",
+			filter:   filter,
+			r:        &ScanRange{},
+			expected: filter,
+		},
+		{
+			name:     "bounded",
+			r:        &ScanRange{Min: min, Max: max},
+			expected: D{{Key: "_id", Value: D{{Key: "$gte", Value: min}, {Key: "$lt", Value: max}}}},
+		},
+		{
+			name:   "bounded resume",
+			filter: filter,
+			r:      &ScanRange{Min: min, Max: max, LastId: last},
+			expected: D{{Key: "$and", Value: A{
+				filter,
+				D{{Key: "_id", Value: D{{Key: "$gt", Value: last}, {Key: "$lt", Value: max}}}},
+			}}},
+		},
+		{
+			name:     "first",
+			r:        &ScanRange{Max: max},
+			expected: D{{Key: "_id", Value: D{{Key: "$lt", Value: max}}}},
+		},
+		{
+			name:     "other types",
+			r:        &ScanRange{ExcludeType: "string"},
+			expected: D{{Key: "_id", Value: D{{Key: "$not", Value: D{{Key: "$type", Value: "string"}}}}}},
+		},
+		{
+			name: "other types resume",
+			r:    &ScanRange{ExcludeType: "string", LastId: last},
+			expected: D{{Key: "$and", Value: A{
+				D{{Key: "$expr", Value: D{{Key: "$gt", Value: A{"$_id", last}}}}},
+				D{{Key: "_id", Value: D{{Key: "$not", Value: D{{Key: "$type", Value: "string"}}}}}},
+			}}},
+		},
+		{
+			name:     "full resume",
+			r:        &ScanRange{LastId: last},
+			expected: D{{Key: "$expr", Value: D{{Key: "$gt", Value: A{"$_id", last}}}}},
+		},
+	}
+
+	for _, test := range tests {
+		if actual := scanFilter(test.filter, test.r); !reflect.DeepEqual(actual, test.expected) {
+			t.Fatalf("%s: 查询条件不匹配 %v", test.name, actual)
+		}
+	}
+}
+
+func TestCheckScanProjection(t *testing.T) {
+	var tests = []struct {
+		projection interface{}
+		expected   error
+	}{
+		{projection: nil, expected: nil},
+		{projection: bson.M{"name": 1}, expected: nil},
+		{projection: bson.D{{Key: "name", Value: 0}}, expected: nil},
+		{projection: bson.M{"_id": 1, "name": 1}, expected: nil},
+		{projection: bson.M{"_id": 0, "name": 1}, expected: ErrScanWithoutId},
+		{projection: bson.D{{Key: "_id", Value: false}}, expected: ErrScanWithoutId},
+		{projection: bson.D{{Key: "_id", Value: int64(0)}}, expected: ErrScanWithoutId},
+		{projection: struct {
+			Id int `bson:"_id"`
+		}{}, expected: ErrScanWithoutId},
+	}
+	for _, test := range tests {
+		if err := checkScanProjection(test.projection); err != test.expected {
+			t.Fatal("projection 检查结果不匹配", test.projection, err)
+		}
+	}
+}
+
+func TestCollection_ResumeScan(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("last id", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.coll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: int32(1)}},
+			bson.D{{Key: "_id", Value: int32(2)}},
+			bson.D{{Key: "name", Value: "x"}},
+		))
+		var c = &collection{collection: mt.Coll}
+
+		var r = &ScanRange{}
+		var err = c.ResumeScan(context.Background(), nil, r, func(ctx context.Context, cur Cursor) error {
+			return nil
+		})
+		// 没有 _id 的文档无法记录处理进度
+		if err != ErrScanWithoutId || r.Done {
+			t.Fatal("没有 _id 时应该返回 ErrScanWithoutId", err)
+		}
+		if r.Count != 2 || !r.LastId.(bson.RawValue).Equal(rawValue(t, int32(2))) {
+			t.Fatal("处理进度不匹配", r.Count, r.LastId)
+		}
+	})
+
+	mt.Run("projection", func(mt *mtest.T) {
+		var c = &collection{collection: mt.Coll}
+		var r = &ScanRange{}
+		var err = c.ResumeScan(context.Background(), nil, r, func(ctx context.Context, cur Cursor) error {
+			return nil
+		}, NewParallelScanOptions().SetProjection(bson.M{"_id": 0}))
+		if err != ErrScanWithoutId {
+			t.Fatal("projection 排除 _id 时应该返回 ErrScanWithoutId", err)
+		}
+		if len(mt.GetAllStartedEvents()) != 0 {
+			t.Fatal("不应该发送任何命令")
+		}
+	})
+
+	mt.Run("canceled", func(mt *mtest.T) {
+		var c = &collection{collection: mt.Coll}
+		var ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		var ranges, err = c.ParallelScan(ctx, nil, 2, func(ctx context.Context, cur Cursor) error {
+			return nil
+		}, NewParallelScanOptions().SetRanges(1))
+		if !errors.Is(err, context.Canceled) || len(ranges) != 1 || ranges[0].Done {
+			t.Fatal("ctx 被取消之后应该返回错误", err)
+		}
+	})
+}
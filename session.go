@@ -34,5 +34,5 @@ func (s *session) BeginTx(ctx context.Context, opts ...*TransactionOptions) (Tx,
 	if err := s.Session.StartTransaction(opts...); err != nil {
 		return nil, err
 	}
-	return &transaction{mongo.NewSessionContext(ctx, s.Session), false}, nil
+	return newTransaction(ctx, s.Session, false), nil
 }
@@ -1,6 +1,7 @@
 package dbm
 
 import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -21,15 +22,44 @@ const (
 	OperationTypeReplace    = "replace"
 	OperationTypeUpdate     = "update"
 	OperationTypeInvalidate = "invalidate"
+
+	OperationTypeDrop         = "drop"
+	OperationTypeRename       = "rename"
+	OperationTypeDropDatabase = "dropDatabase"
+
+	// 以下事件需要开启 ChangeStreamOptions.ShowExpandedEvents（6.0+）
+	OperationTypeCreate                   = "create"
+	OperationTypeCreateIndexes            = "createIndexes"
+	OperationTypeDropIndexes              = "dropIndexes"
+	OperationTypeModify                   = "modify"
+	OperationTypeShardCollection          = "shardCollection"
+	OperationTypeReshardCollection        = "reshardCollection"
+	OperationTypeRefineCollectionShardKey = "refineCollectionShardKey"
 )
 
 type ChangeEvent struct {
-	Id            EventId       `bson:"_id"`
-	OperationType OperationType `bson:"operationType"`
-	ClusterTime   Timestamp     `bson:"clusterTime"`
-	Namespace     Namespace     `bson:"ns"`
-	WallTime      DateTime      `bson:"wallTime"`
-	DocumentKey   DocumentKey   `bson:"documentKey"`
+	Id                   EventId            `bson:"_id"`
+	OperationType        OperationType      `bson:"operationType"`
+	ClusterTime          Timestamp          `bson:"clusterTime"`
+	Namespace            Namespace          `bson:"ns"`
+	WallTime             DateTime           `bson:"wallTime"`
+	DocumentKey          DocumentKey        `bson:"documentKey"`
+	UpdateDescription    *UpdateDescription `bson:"updateDescription,omitempty"`
+	To                   *Namespace         `bson:"to,omitempty"`
+	TxnNumber            *int64             `bson:"txnNumber,omitempty"`
+	LSID                 *LSID              `bson:"lsid,omitempty"`
+	CollectionUUID       *primitive.Binary  `bson:"collectionUUID,omitempty"`
+	OperationDescription Raw                `bson:"operationDescription,omitempty"`
+}
+
+// ChangeEventOf 包含指定类型的 fullDocument 和 fullDocumentBeforeChange。
+//
+// fullDocument 需要设置 ChangeStreamOptions.FullDocument，fullDocumentBeforeChange 需要设置
+// ChangeStreamOptions.FullDocumentBeforeChange 并且集合开启了 changeStreamPreAndPostImages（6.0+）。
+type ChangeEventOf[T any] struct {
+	ChangeEvent              `bson:",inline"`
+	FullDocument             *T `bson:"fullDocument,omitempty"`
+	FullDocumentBeforeChange *T `bson:"fullDocumentBeforeChange,omitempty"`
 }
 
 type EventId struct {
@@ -41,6 +71,25 @@ type Namespace struct {
 	Collection string `bson:"coll"`
 }
 
+// DocumentKey 的 Id 可以是任意类型，如 string、ObjectId 等，分片集合中还会包含分片键。
 type DocumentKey struct {
-	Id string `bson:"_id"`
+	Id interface{} `bson:"_id"`
+}
+
+type UpdateDescription struct {
+	UpdatedFields      M                `bson:"updatedFields"`
+	RemovedFields      []string         `bson:"removedFields"`
+	TruncatedArrays    []TruncatedArray `bson:"truncatedArrays"`
+	DisambiguatedPaths M                `bson:"disambiguatedPaths,omitempty"`
+}
+
+type TruncatedArray struct {
+	Field   string `bson:"field"`
+	NewSize int32  `bson:"newSize"`
+}
+
+// LSID 事务所在会话的标识
+type LSID struct {
+	Id  primitive.Binary `bson:"id"`
+	UID primitive.Binary `bson:"uid"`
 }
@@ -0,0 +1,128 @@
+package dbm_test
+
+import (
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestChangeEvent_DocumentKey(t *testing.T) {
+	var oid = dbm.NewObjectId()
+	var tests = []struct {
+		key      interface{}
+		expected interface{}
+	}{
+		{key: oid, expected: oid},
+		{key: "u1", expected: "u1"},
+		{key: int64(10), expected: int64(10)},
+	}
+
+	for _, test := range tests {
+		var data, _ = bson.Marshal(dbm.M{
+			"_id":           dbm.M{"_data": "8263"},
+			"operationType": dbm.OperationTypeDelete,
+			"documentKey":   dbm.D{{Key: "_id", Value: test.key}, {Key: "region", Value: "cn"}},
+		})
+
+		var event dbm.ChangeEvent
+		if err := bson.Unmarshal(data, &event); err != nil {
+			t.Fatal("解析事件发生错误", err)
+		}
+		if event.DocumentKey.Id != test.expected {
+			t.Fatalf("documentKey 不匹配 %T %v", event.DocumentKey.Id, event.DocumentKey.Id)
+		}
+	}
+}
+
+func TestChangeEvent_Fields(t *testing.T) {
+	var uuid = primitive.Binary{Subtype: 4, Data: []byte("0123456789abcdef")}
+	var wallTime = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var data, _ = bson.Marshal(dbm.D{
+		{Key: "_id", Value: dbm.M{"_data": "8263"}},
+		{Key: "operationType", Value: dbm.OperationTypeUpdate},
+		{Key: "clusterTime", Value: dbm.Timestamp{T: 1714521600, I: 3}},
+		{Key: "wallTime", Value: primitive.NewDateTimeFromTime(wallTime)},
+		{Key: "ns", Value: dbm.M{"db": "test", "coll": "user"}},
+		{Key: "documentKey", Value: dbm.M{"_id": "u1"}},
+		{Key: "updateDescription", Value: dbm.D{
+			{Key: "updatedFields", Value: dbm.M{"name": "Yang", "tags.0": "a"}},
+			{Key: "removedFields", Value: dbm.A{"age"}},
+			{Key: "truncatedArrays", Value: dbm.A{dbm.M{"field": "tags", "newSize": int32(1)}}},
+			{Key: "disambiguatedPaths", Value: dbm.M{"tags.0": dbm.A{"tags", "0"}}},
+		}},
+		{Key: "txnNumber", Value: int64(7)},
+		{Key: "lsid", Value: dbm.M{"id": uuid, "uid": primitive.Binary{Data: []byte("uid")}}},
+		{Key: "collectionUUID", Value: uuid},
+		{Key: "fullDocument", Value: dbm.M{"_id": "u1", "name": "Yang", "age": 10}},
+		{Key: "fullDocumentBeforeChange", Value: dbm.M{"_id": "u1", "name": "Feng", "age": 9}},
+	})
+
+	var event dbm.ChangeEventOf[User]
+	if err := bson.Unmarshal(data, &event); err != nil {
+		t.Fatal("解析事件发生错误", err)
+	}
+
+	if event.Id.Data != "8263" || event.OperationType != dbm.OperationTypeUpdate {
+		t.Fatal("事件基本信息不匹配", event.Id, event.OperationType)
+	}
+	if event.ClusterTime.T != 1714521600 || event.ClusterTime.I != 3 || !event.WallTime.Time().Equal(wallTime) {
+		t.Fatal("事件时间不匹配", event.ClusterTime, event.WallTime)
+	}
+	if event.Namespace.Database != "test" || event.Namespace.Collection != "user" || event.DocumentKey.Id != "u1" {
+		t.Fatal("事件命名空间不匹配", event.Namespace, event.DocumentKey)
+	}
+
+	var desc = event.UpdateDescription
+	if desc == nil || desc.UpdatedFields["name"] != "Yang" || len(desc.RemovedFields) != 1 || desc.RemovedFields[0] != "age" {
+		t.Fatal("updateDescription 不匹配", desc)
+	}
+	if len(desc.TruncatedArrays) != 1 || desc.TruncatedArrays[0].Field != "tags" || desc.TruncatedArrays[0].NewSize != 1 {
+		t.Fatal("truncatedArrays 不匹配", desc.TruncatedArrays)
+	}
+	if _, ok := desc.DisambiguatedPaths["tags.0"]; !ok {
+		t.Fatal("disambiguatedPaths 不匹配", desc.DisambiguatedPaths)
+	}
+
+	if event.TxnNumber == nil || *event.TxnNumber != 7 || event.LSID == nil || string(event.LSID.UID.Data) != "uid" {
+		t.Fatal("事务信息不匹配", event.TxnNumber, event.LSID)
+	}
+	if event.CollectionUUID == nil || string(event.CollectionUUID.Data) != "0123456789abcdef" {
+		t.Fatal("collectionUUID 不匹配", event.CollectionUUID)
+	}
+	if event.To != nil || event.OperationDescription != nil {
+		t.Fatal("不存在的字段应该为空", event.To, event.OperationDescription)
+	}
+
+	if event.FullDocument == nil || event.FullDocument.Name != "Yang" || event.FullDocument.Age != 10 {
+		t.Fatal("fullDocument 不匹配", event.FullDocument)
+	}
+	if event.FullDocumentBeforeChange == nil || event.FullDocumentBeforeChange.Name != "Feng" {
+		t.Fatal("fullDocumentBeforeChange 不匹配", event.FullDocumentBeforeChange)
+	}
+}
+
+func TestChangeEvent_Rename(t *testing.T) {
+	var data, _ = bson.Marshal(dbm.M{
+		"_id":                  dbm.M{"_data": "8263"},
+		"operationType":        dbm.OperationTypeRename,
+		"ns":                   dbm.M{"db": "test", "coll": "user"},
+		"to":                   dbm.M{"db": "test", "coll": "member"},
+		"operationDescription": dbm.M{"to": dbm.M{"db": "test", "coll": "member"}},
+	})
+
+	var event dbm.ChangeEventOf[User]
+	if err := bson.Unmarshal(data, &event); err != nil {
+		t.Fatal("解析事件发生错误", err)
+	}
+	if event.To == nil || event.To.Collection != "member" {
+		t.Fatal("to 不匹配", event.To)
+	}
+	if value, err := event.OperationDescription.LookupErr("to", "coll"); err != nil || value.StringValue() != "member" {
+		t.Fatal("operationDescription 不匹配", event.OperationDescription)
+	}
+	if event.FullDocument != nil || event.UpdateDescription != nil || event.DocumentKey.Id != nil {
+		t.Fatal("不存在的字段应该为空", event.FullDocument, event.UpdateDescription, event.DocumentKey)
+	}
+}
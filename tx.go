@@ -3,6 +3,8 @@ package dbm
 import (
 	"context"
 	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+	"time"
 )
 
 type Tx interface {
@@ -11,23 +13,454 @@ type Tx interface {
 	Commit(ctx context.Context) error
 
 	Rollback(ctx context.Context) error
+
+	// OnCommit 注册事务提交成功之后执行的函数
+	OnCommit(fn func(ctx context.Context))
+
+	// OnRollback 注册事务回滚之后执行的函数，不管回滚操作是否成功都会执行
+	OnRollback(fn func(ctx context.Context))
+
+	// IsReal 返回是否为真实的事务，服务器不支持事务并且开启了 Config.FallbackTx 时返回 false
+	IsReal() bool
+}
+
+type txKey struct{}
+
+// TxFromContext 从 ctx 中获取当前的事务，ctx 为 Tx 或者由 Tx 派生的 context 时返回该事务。
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	var tx, ok = ctx.Value(txKey{}).(Tx)
+	return tx, ok
 }
 
 type transaction struct {
 	mongo.SessionContext
+	txState
 	automatic bool
 }
 
+func newTransaction(ctx context.Context, sess mongo.Session, automatic bool) *transaction {
+	return &transaction{SessionContext: mongo.NewSessionContext(ctx, sess), automatic: automatic}
+}
+
+func (tx *transaction) Value(key interface{}) interface{} {
+	if _, ok := key.(txKey); ok {
+		return tx
+	}
+	return tx.SessionContext.Value(key)
+}
+
 func (tx *transaction) Commit(ctx context.Context) error {
 	if tx.automatic {
 		defer tx.SessionContext.EndSession(ctx)
 	}
-	return tx.SessionContext.CommitTransaction(ctx)
+	if tx.isRollbackOnly() {
+		tx.abort(ctx)
+		tx.runHooks(ctx, false)
+		return ErrTxRollbackOnly
+	}
+	if err := tx.SessionContext.CommitTransaction(ctx); err != nil {
+		return err
+	}
+	tx.runHooks(ctx, true)
+	return nil
+}
+
+func (tx *transaction) IsReal() bool {
+	return true
 }
 
 func (tx *transaction) Rollback(ctx context.Context) error {
 	if tx.automatic {
 		defer tx.SessionContext.EndSession(ctx)
 	}
+	defer tx.runHooks(ctx, false)
+	return tx.abort(ctx)
+}
+
+// abort 回滚事务，同一个事务只会回滚一次
+func (tx *transaction) abort(ctx context.Context) error {
+	if !tx.markAborted() {
+		return nil
+	}
 	return tx.SessionContext.AbortTransaction(ctx)
 }
+
+// txState 保存事务的回调和状态
+type txState struct {
+	mu           sync.Mutex
+	onCommit     []func(ctx context.Context)
+	onRollback   []func(ctx context.Context)
+	rollbackOnly bool
+	failFast     bool
+	aborted      bool
+}
+
+func (h *txState) OnCommit(fn func(ctx context.Context)) {
+	if fn == nil {
+		return
+	}
+	h.mu.Lock()
+	h.onCommit = append(h.onCommit, fn)
+	h.mu.Unlock()
+}
+
+func (h *txState) OnRollback(fn func(ctx context.Context)) {
+	if fn == nil {
+		return
+	}
+	h.mu.Lock()
+	h.onRollback = append(h.onRollback, fn)
+	h.mu.Unlock()
+}
+
+// runHooks 执行 commit 或者 rollback 回调，每个事务的回调只会被执行一次
+func (h *txState) runHooks(ctx context.Context, committed bool) {
+	h.mu.Lock()
+	var hooks = h.onRollback
+	if committed {
+		hooks = h.onCommit
+	}
+	h.onCommit = nil
+	h.onRollback = nil
+	h.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
+
+// markRollbackOnly 标记事务只能回滚，之后调用 Commit 会回滚事务并返回 ErrTxRollbackOnly；
+// failFast 为 true 时之后在该事务中执行的操作会直接返回 ErrTxRollbackOnly
+func (h *txState) markRollbackOnly(failFast bool) {
+	h.mu.Lock()
+	h.rollbackOnly = true
+	if failFast {
+		h.failFast = true
+	}
+	h.mu.Unlock()
+}
+
+func (h *txState) isFailFast() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failFast
+}
+
+func (h *txState) isRollbackOnly() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rollbackOnly
+}
+
+// markAborted 标记事务已经回滚，事务之前没有被回滚时返回 true
+func (h *txState) markAborted() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.aborted {
+		return false
+	}
+	h.aborted = true
+	return true
+}
+
+// noopTx 用于不支持事务的服务器，其中的操作会直接执行，Commit 和 Rollback 只会执行回调
+type noopTx struct {
+	context.Context
+	txState
+}
+
+func newNoopTx(ctx context.Context) *noopTx {
+	return &noopTx{Context: ctx}
+}
+
+func (tx *noopTx) Value(key interface{}) interface{} {
+	if _, ok := key.(txKey); ok {
+		return tx
+	}
+	return tx.Context.Value(key)
+}
+
+func (tx *noopTx) Commit(ctx context.Context) error {
+	if tx.isRollbackOnly() {
+		tx.runHooks(ctx, false)
+		return ErrTxRollbackOnly
+	}
+	tx.runHooks(ctx, true)
+	return nil
+}
+
+func (tx *noopTx) Rollback(ctx context.Context) error {
+	tx.markAborted()
+	tx.runHooks(ctx, false)
+	return nil
+}
+
+func (tx *noopTx) IsReal() bool {
+	return false
+}
+
+// rootTx 是可以被加入的事务，即由 BeginTx 或者 WithTx 开启的 transaction 和 noopTx
+type rootTx interface {
+	Tx
+	markRollbackOnly(failFast bool)
+	isFailFast() bool
+}
+
+// rootTxOf 返回 tx 所属的 rootTx，加入的事务返回外层事务
+func rootTxOf(tx Tx) (rootTx, bool) {
+	switch tx := tx.(type) {
+	case *joinedTx:
+		return tx.root, true
+	case rootTx:
+		return tx, true
+	}
+	return nil, false
+}
+
+// checkTx 在 ctx 中的事务已经被 PropagationNested 加入的事务回滚时返回 ErrTxRollbackOnly
+func checkTx(ctx context.Context) error {
+	var tx, ok = TxFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if root, ok := rootTxOf(tx); ok && root.isFailFast() {
+		return ErrTxRollbackOnly
+	}
+	return nil
+}
+
+type Propagation int
+
+const (
+	// PropagationRequired 加入 ctx 中已经存在的事务，不存在时开启新的事务；
+	// 加入的事务回滚时外层事务会被标记为只能回滚，外层事务提交时回滚并返回 ErrTxRollbackOnly
+	PropagationRequired Propagation = iota
+
+	// PropagationRequiresNew 总是使用新的 Session 开启新的事务，与 ctx 中已经存在的事务互不影响
+	PropagationRequiresNew
+
+	// PropagationNested 加入 ctx 中已经存在的事务，不存在时开启新的事务；
+	// mongodb 不支持 savepoint，加入的事务回滚时外层事务会被标记为只能回滚，之后通过 dbm 在外层事务中执行的操作会直接返回 ErrTxRollbackOnly，
+	// 外层事务在其 Commit 或者 Rollback 时回滚
+	PropagationNested
+)
+
+// joinedTx 加入外层事务，Commit 不会提交事务，由外层事务负责提交
+type joinedTx struct {
+	context.Context
+	root   rootTx
+	nested bool
+}
+
+// joinTx 返回加入 ctx 中已经存在的事务的 Tx，ctx 中没有事务时返回 nil
+func joinTx(ctx context.Context, propagation Propagation) *joinedTx {
+	if propagation == PropagationRequiresNew {
+		return nil
+	}
+	var parent, ok = TxFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	root, ok := rootTxOf(parent)
+	if !ok {
+		return nil
+	}
+	return &joinedTx{Context: ctx, root: root, nested: propagation == PropagationNested}
+}
+
+func (tx *joinedTx) Value(key interface{}) interface{} {
+	if _, ok := key.(txKey); ok {
+		return tx
+	}
+	return tx.Context.Value(key)
+}
+
+func (tx *joinedTx) Commit(ctx context.Context) error {
+	return nil
+}
+
+// Rollback 不会回滚外层事务，只是将其标记为只能回滚，由外层事务在其 Commit 或者 Rollback 时回滚
+func (tx *joinedTx) Rollback(ctx context.Context) error {
+	tx.root.markRollbackOnly(tx.nested)
+	return nil
+}
+
+// OnCommit 注册的函数在外层事务提交之后执行
+func (tx *joinedTx) OnCommit(fn func(ctx context.Context)) {
+	tx.root.OnCommit(fn)
+}
+
+// OnRollback 注册的函数在外层事务回滚之后执行
+func (tx *joinedTx) OnRollback(fn func(ctx context.Context)) {
+	tx.root.OnRollback(fn)
+}
+
+func (tx *joinedTx) IsReal() bool {
+	return tx.root.IsReal()
+}
+
+type TxOptions struct {
+	TransactionOptions *TransactionOptions
+
+	// Propagation 为 ctx 中已经存在事务时的处理方式，默认为 PropagationRequired
+	Propagation Propagation
+
+	// Timeout 为重试的最长时间，从第一次执行开始计算，默认为 120 秒
+	Timeout time.Duration
+
+	// MinBackoff 和 MaxBackoff 为重试的最小和最大等待时间，每次重试之后等待时间翻倍
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewTxOptions() *TxOptions {
+	return &TxOptions{}
+}
+
+func (opts *TxOptions) SetTransactionOptions(txOpts *TransactionOptions) *TxOptions {
+	opts.TransactionOptions = txOpts
+	return opts
+}
+
+func (opts *TxOptions) SetPropagation(propagation Propagation) *TxOptions {
+	opts.Propagation = propagation
+	return opts
+}
+
+func (opts *TxOptions) SetTimeout(timeout time.Duration) *TxOptions {
+	opts.Timeout = timeout
+	return opts
+}
+
+func (opts *TxOptions) SetBackoff(min, max time.Duration) *TxOptions {
+	opts.MinBackoff = min
+	opts.MaxBackoff = max
+	return opts
+}
+
+func mergeTxOptions(opts ...*TxOptions) *TxOptions {
+	var nOpts = NewTxOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.TransactionOptions != nil {
+			nOpts.TransactionOptions = opt.TransactionOptions
+		}
+		if opt.Propagation != PropagationRequired {
+			nOpts.Propagation = opt.Propagation
+		}
+		if opt.Timeout > 0 {
+			nOpts.Timeout = opt.Timeout
+		}
+		if opt.MinBackoff > 0 {
+			nOpts.MinBackoff = opt.MinBackoff
+		}
+		if opt.MaxBackoff > 0 {
+			nOpts.MaxBackoff = opt.MaxBackoff
+		}
+	}
+	if nOpts.Timeout <= 0 {
+		nOpts.Timeout = 120 * time.Second
+	}
+	if nOpts.MinBackoff <= 0 {
+		nOpts.MinBackoff = 10 * time.Millisecond
+	}
+	if nOpts.MaxBackoff <= 0 {
+		nOpts.MaxBackoff = time.Second
+	}
+	if nOpts.MaxBackoff < nOpts.MinBackoff {
+		nOpts.MaxBackoff = nOpts.MinBackoff
+	}
+	return nOpts
+}
+
+// withTx 在事务中执行 fn，fn 返回错误或者 panic 时回滚事务。
+//
+// 错误包含 TransientTransactionError 标签时重新执行整个 fn，提交时错误包含 UnknownTransactionCommitResult 标签时只重试提交。
+func withTx(ctx context.Context, sess mongo.Session, fn func(tx Tx) error, opts *TxOptions) error {
+	var deadline = time.Now().Add(opts.Timeout)
+	return retryWithBackoff(ctx, deadline, opts, IsTransient, func() error {
+		return runTx(ctx, sess, fn, opts, deadline)
+	})
+}
+
+func runTx(ctx context.Context, sess mongo.Session, fn func(tx Tx) error, opts *TxOptions, deadline time.Time) (err error) {
+	if err = sess.StartTransaction(opts.TransactionOptions); err != nil {
+		return err
+	}
+
+	var tx = newTransaction(ctx, sess, false)
+	var done bool
+	defer func() {
+		if done {
+			return
+		}
+		// ctx 可能已经被取消，回滚时使用新的 context
+		if r := recover(); r != nil {
+			tx.Rollback(context.Background())
+			panic(r)
+		}
+		tx.Rollback(context.Background())
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	err = retryWithBackoff(ctx, deadline, opts, isUnknownCommitResult, func() error {
+		return tx.Commit(ctx)
+	})
+	done = true
+	if err != nil {
+		// 调用过 commitTransaction 之后不能再回滚事务，只执行 rollback 回调
+		tx.runHooks(context.Background(), false)
+	}
+	return err
+}
+
+func isUnknownCommitResult(err error) bool {
+	return hasErrorLabel(err, labelUnknownTransactionCommit)
+}
+
+// retryWithBackoff 执行 fn 直到成功、错误不可重试、超过 deadline 或者 ctx 被取消，每次重试之前的等待时间翻倍
+func retryWithBackoff(ctx context.Context, deadline time.Time, opts *TxOptions, retryable func(err error) bool, fn func() error) error {
+	var backoff = opts.MinBackoff
+	for {
+		var err = fn()
+		if err == nil || !retryable(err) || !time.Now().Before(deadline) {
+			return err
+		}
+
+		var timer = time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// runLocalTx 在不需要重试的 Tx（noopTx 和 joinedTx）中执行 fn
+func runLocalTx(ctx context.Context, tx Tx, fn func(tx Tx) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback(ctx)
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,406 @@
+package dbm
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+	"time"
+)
+
+func unknownCommitResultResponse() bson.D {
+	return mtest.CreateCommandErrorResponse(mtest.CommandError{
+		Code:    50,
+		Name:    "MaxTimeMSExpired",
+		Message: "operation exceeded time limit",
+		Labels:  []string{labelUnknownTransactionCommit},
+	})
+}
+
+func countCommands(mt *mtest.T, name string) int {
+	return len(startedCommands(mt, name))
+}
+
+func startedCommands(mt *mtest.T, name string) []*event.CommandStartedEvent {
+	var events []*event.CommandStartedEvent
+	for _, evt := range mt.GetAllStartedEvents() {
+		if evt.CommandName == name {
+			events = append(events, evt)
+		}
+	}
+	return events
+}
+
+func TestWithTx_CommitRetryBackoff(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("commit after retry", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			unknownCommitResultResponse(),
+			unknownCommitResultResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		var sess, err = mt.Client.StartSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.EndSession(context.Background())
+
+		var committed, rolledBack int
+		var opts = mergeTxOptions(NewTxOptions().SetBackoff(20*time.Millisecond, time.Second))
+		var begin = time.Now()
+		err = withTx(context.Background(), sess, func(tx Tx) error {
+			tx.OnCommit(func(ctx context.Context) { committed++ })
+			tx.OnRollback(func(ctx context.Context) { rolledBack++ })
+			_, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}})
+			return err
+		}, opts)
+		if err != nil {
+			t.Fatal("提交事务发生错误", err)
+		}
+
+		if n := countCommands(mt, "commitTransaction"); n != 3 {
+			t.Fatal("commitTransaction 的次数不匹配", n)
+		}
+		// 两次重试之前分别等待 20ms 和 40ms
+		if elapsed := time.Since(begin); elapsed < 60*time.Millisecond {
+			t.Fatal("重试提交之前应该等待", elapsed)
+		}
+		if committed != 1 || rolledBack != 0 {
+			t.Fatal("回调执行的次数不匹配", committed, rolledBack)
+		}
+	})
+
+	mt.Run("timeout", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		for i := 0; i < 100; i++ {
+			mt.AddMockResponses(unknownCommitResultResponse())
+		}
+
+		var sess, err = mt.Client.StartSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.EndSession(context.Background())
+
+		var rolledBack int
+		var opts = mergeTxOptions(NewTxOptions().SetTimeout(100*time.Millisecond).SetBackoff(20*time.Millisecond, 40*time.Millisecond))
+		err = withTx(context.Background(), sess, func(tx Tx) error {
+			tx.OnRollback(func(ctx context.Context) { rolledBack++ })
+			_, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}})
+			return err
+		}, opts)
+		if !isUnknownCommitResult(err) {
+			t.Fatal("应该返回 UnknownTransactionCommitResult 错误", err)
+		}
+
+		// 100ms 内最多重试 20ms、40ms、40ms 三次
+		if n := countCommands(mt, "commitTransaction"); n < 2 || n > 5 {
+			t.Fatal("commitTransaction 的次数不匹配", n)
+		}
+		if rolledBack != 1 {
+			t.Fatal("提交失败时应该执行 rollback 回调", rolledBack)
+		}
+	})
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var opts = mergeTxOptions(NewTxOptions().SetBackoff(10*time.Millisecond, 20*time.Millisecond))
+	var retryable = func(err error) bool { return err == context.DeadlineExceeded }
+
+	// 不可重试的错误
+	var calls int
+	var err = retryWithBackoff(context.Background(), time.Now().Add(time.Second), opts, retryable, func() error {
+		calls++
+		return context.Canceled
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Fatal("不可重试的错误不应该重试", err, calls)
+	}
+
+	// 等待时间翻倍，并且不超过 MaxBackoff
+	var times []time.Time
+	err = retryWithBackoff(context.Background(), time.Now().Add(time.Second), opts, retryable, func() error {
+		times = append(times, time.Now())
+		if len(times) < 4 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	})
+	if err != nil || len(times) != 4 {
+		t.Fatal("重试的次数不匹配", err, len(times))
+	}
+	var expected = []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond}
+	for i, wait := range expected {
+		if d := times[i+1].Sub(times[i]); d < wait {
+			t.Fatal("重试之前的等待时间不匹配", i, d)
+		}
+	}
+
+	// ctx 被取消之后不再重试
+	var ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	err = retryWithBackoff(ctx, time.Now().Add(time.Second), opts, retryable, func() error {
+		calls++
+		cancel()
+		return context.DeadlineExceeded
+	})
+	if err != context.DeadlineExceeded || calls != 1 {
+		t.Fatal("ctx 被取消之后不应该重试", err, calls)
+	}
+}
+
+// newStandaloneClient 返回不支持事务的 Client，不需要连接服务器
+func newStandaloneClient(fallback bool, onFallback func(ctx context.Context)) *client {
+	var cfg = NewConfig("mongodb://localhost:27017")
+	cfg.SetFallbackTx(fallback)
+	cfg.SetOnFallbackTx(onFallback)
+	return &client{serverInfo: &serverInfo{}, config: cfg}
+}
+
+func TestClient_FallbackTx(t *testing.T) {
+	var fallbacks int
+	var c = newStandaloneClient(true, func(ctx context.Context) {
+		fallbacks++
+	})
+
+	var tx, err = c.BeginTx(context.Background())
+	if err != nil {
+		t.Fatal("开启事务发生错误", err)
+	}
+	if tx.IsReal() {
+		t.Fatal("不支持事务时 IsReal 应该返回 false")
+	}
+	if current, ok := TxFromContext(tx); !ok || current != tx {
+		t.Fatal("应该可以从 ctx 中获取事务")
+	}
+
+	var committed int
+	tx.OnCommit(func(ctx context.Context) { committed++ })
+	if err = tx.Commit(context.Background()); err != nil || committed != 1 {
+		t.Fatal("提交事务发生错误", err, committed)
+	}
+
+	var rolledBack int
+	var fnErr = context.Canceled
+	err = c.WithTx(context.Background(), func(tx Tx) error {
+		if tx.IsReal() {
+			t.Fatal("不支持事务时 IsReal 应该返回 false")
+		}
+		tx.OnCommit(func(ctx context.Context) { committed++ })
+		tx.OnRollback(func(ctx context.Context) { rolledBack++ })
+		return fnErr
+	})
+	if err != fnErr || committed != 1 || rolledBack != 1 {
+		t.Fatal("fn 返回错误时应该回滚事务", err, committed, rolledBack)
+	}
+
+	err = c.WithTx(context.Background(), func(tx Tx) error {
+		tx.OnCommit(func(ctx context.Context) { committed++ })
+		return nil
+	})
+	if err != nil || committed != 2 {
+		t.Fatal("提交事务发生错误", err, committed)
+	}
+
+	if fallbacks != 3 {
+		t.Fatal("OnFallbackTx 调用的次数不匹配", fallbacks)
+	}
+}
+
+func TestClient_FallbackTxDisabled(t *testing.T) {
+	var c = newStandaloneClient(false, func(ctx context.Context) {
+		t.Fatal("没有开启 FallbackTx 时不应该调用 OnFallbackTx")
+	})
+
+	if _, err := c.BeginTx(context.Background()); err != ErrSessionNotSupported {
+		t.Fatal("不支持事务时应该返回 ErrSessionNotSupported", err)
+	}
+	var err = c.WithTx(context.Background(), func(tx Tx) error {
+		t.Fatal("不应该执行 fn")
+		return nil
+	})
+	if err != ErrSessionNotSupported {
+		t.Fatal("不支持事务时应该返回 ErrSessionNotSupported", err)
+	}
+
+	// 支持事务时不会使用 FallbackTx
+	c = newStandaloneClient(true, func(ctx context.Context) {
+		t.Fatal("支持事务时不应该调用 OnFallbackTx")
+	})
+	c.transactionAllowed = true
+	if c.fallbackTx(context.Background()) {
+		t.Fatal("支持事务时不应该使用 FallbackTx")
+	}
+}
+
+// newMockClient 返回使用 mock 服务器的 Client，支持 Session 和事务
+func newMockClient(mt *mtest.T) *client {
+	return &client{serverInfo: &serverInfo{transactionAllowed: true}, config: NewConfig("mongodb://localhost:27017"), client: mt.Client}
+}
+
+func TestClient_WithTxPropagation(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	var errBad = context.Canceled
+
+	mt.Run("required", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		var c = newMockClient(mt)
+
+		var rolledBack int
+		var err = c.WithTx(context.Background(), func(tx Tx) error {
+			tx.OnRollback(func(ctx context.Context) { rolledBack++ })
+			if _, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+				return err
+			}
+			if err := c.WithTx(tx, func(inner Tx) error {
+				if inner == tx || !inner.IsReal() {
+					t.Fatal("内层应该加入外层事务")
+				}
+				return errBad
+			}); err != errBad {
+				t.Fatal("内层事务应该返回 fn 的错误", err)
+			}
+			// 内层回滚之后不会立即回滚外层事务
+			if countCommands(mt, "abortTransaction") != 0 {
+				t.Fatal("不应该立即回滚外层事务")
+			}
+			return nil
+		})
+		if err != ErrTxRollbackOnly {
+			t.Fatal("外层事务应该返回 ErrTxRollbackOnly", err)
+		}
+		if countCommands(mt, "commitTransaction") != 0 || countCommands(mt, "abortTransaction") != 1 || rolledBack != 1 {
+			t.Fatal("外层事务应该被回滚", rolledBack)
+		}
+	})
+
+	mt.Run("nested", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		var c = newMockClient(mt)
+		var coll = &collection{collection: mt.Coll}
+
+		var rolledBack int
+		var err = c.WithTx(context.Background(), func(tx Tx) error {
+			tx.OnRollback(func(ctx context.Context) { rolledBack++ })
+			if _, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+				return err
+			}
+			var nested = NewTxOptions().SetPropagation(PropagationNested)
+			if err := c.WithTx(tx, func(inner Tx) error {
+				return errBad
+			}, nested); err != errBad {
+				t.Fatal("内层事务应该返回 fn 的错误", err)
+			}
+			// 内层回滚之后不会立即回滚外层事务，外层事务中的操作仍然在事务中执行
+			if countCommands(mt, "abortTransaction") != 0 {
+				t.Fatal("不应该立即回滚外层事务")
+			}
+			if _, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 2}}); err != nil {
+				return err
+			}
+			var inserts = startedCommands(mt, "insert")
+			if len(inserts) != 2 {
+				t.Fatal("insert 命令数量不匹配", len(inserts))
+			}
+			var first, ok1 = inserts[0].Command.Lookup("txnNumber").Int64OK()
+			var second, ok2 = inserts[1].Command.Lookup("txnNumber").Int64OK()
+			if !ok1 || !ok2 || first != second {
+				t.Fatal("之后的操作应该在外层事务中执行", first, second)
+			}
+
+			// 通过 dbm 执行的操作直接返回错误
+			if _, err := coll.InsertOne(tx, bson.D{{Key: "_id", Value: 3}}); err != ErrTxRollbackOnly {
+				t.Fatal("应该返回 ErrTxRollbackOnly", err)
+			}
+			if len(startedCommands(mt, "insert")) != 2 {
+				t.Fatal("不应该发送 insert 命令")
+			}
+			return nil
+		})
+		if err != ErrTxRollbackOnly {
+			t.Fatal("外层事务应该返回 ErrTxRollbackOnly", err)
+		}
+		if countCommands(mt, "commitTransaction") != 0 || countCommands(mt, "abortTransaction") != 1 || rolledBack != 1 {
+			t.Fatal("外层事务结束时应该只被回滚一次", rolledBack)
+		}
+	})
+
+	mt.Run("requires new", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(), // 外层 insert
+			mtest.CreateSuccessResponse(), // 内层 insert
+			mtest.CreateSuccessResponse(), // 内层 commitTransaction
+			mtest.CreateSuccessResponse(), // 外层 abortTransaction
+		)
+		var c = newMockClient(mt)
+
+		var innerCommitted, outerRolledBack int
+		var err = c.WithTx(context.Background(), func(tx Tx) error {
+			tx.OnRollback(func(ctx context.Context) { outerRolledBack++ })
+			if _, err := mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+				return err
+			}
+
+			var requiresNew = NewTxOptions().SetPropagation(PropagationRequiresNew)
+			if err := c.WithTx(tx, func(inner Tx) error {
+				var outerSess = mongo.SessionFromContext(tx)
+				var innerSess = mongo.SessionFromContext(inner)
+				if innerSess == nil || outerSess == nil || bytes.Equal(innerSess.ID(), outerSess.ID()) {
+					t.Fatal("内层事务应该使用新的 Session")
+				}
+				inner.OnCommit(func(ctx context.Context) { innerCommitted++ })
+				_, err := mt.Coll.InsertOne(inner, bson.D{{Key: "_id", Value: 2}})
+				return err
+			}, requiresNew); err != nil {
+				t.Fatal("内层事务应该独立提交", err)
+			}
+			return errBad
+		})
+		if err != errBad {
+			t.Fatal("外层事务应该返回 fn 的错误", err)
+		}
+		if countCommands(mt, "commitTransaction") != 1 || countCommands(mt, "abortTransaction") != 1 {
+			t.Fatal("内层事务应该提交，外层事务应该回滚")
+		}
+		if innerCommitted != 1 || outerRolledBack != 1 {
+			t.Fatal("回调执行的次数不匹配", innerCommitted, outerRolledBack)
+		}
+	})
+
+	mt.Run("begin tx with", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		var c = newMockClient(mt)
+
+		var tx, err = c.BeginTx(context.Background())
+		if err != nil {
+			t.Fatal("开启事务发生错误", err)
+		}
+		if _, err = mt.Coll.InsertOne(tx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+			t.Fatal(err)
+		}
+
+		inner, err := c.BeginTxWith(tx, PropagationRequired)
+		if err != nil {
+			t.Fatal("加入事务发生错误", err)
+		}
+		if err = inner.Commit(inner); err != nil || countCommands(mt, "commitTransaction") != 0 {
+			t.Fatal("加入的事务不应该提交外层事务", err)
+		}
+		if err = inner.Rollback(inner); err != nil || countCommands(mt, "abortTransaction") != 0 {
+			t.Fatal("加入的事务不应该立即回滚外层事务", err)
+		}
+
+		if err = tx.Commit(tx); err != ErrTxRollbackOnly {
+			t.Fatal("外层事务应该返回 ErrTxRollbackOnly", err)
+		}
+		if countCommands(mt, "abortTransaction") != 1 {
+			t.Fatal("外层事务应该被回滚")
+		}
+	})
+}
@@ -0,0 +1,677 @@
+package dbm
+
+import (
+	"context"
+	"time"
+)
+
+type TypedCollection[T any] interface {
+	Collection() Collection
+
+	Name() string
+
+	InsertOne(ctx context.Context, document T, opts ...*InsertOneOptions) (*InsertOneResult, error)
+
+	InsertOneNx(ctx context.Context, filter interface{}, document T, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	InsertMany(ctx context.Context, documents []T, opts ...*InsertManyOptions) (*InsertManyResult, error)
+
+	Insert(ctx context.Context, documents ...T) (*InsertManyResult, error)
+
+	RepsertOne(ctx context.Context, filter interface{}, replacement T, opts ...*ReplaceOptions) (*UpdateResult, error)
+
+	ReplaceOne(ctx context.Context, filter interface{}, replacement T, opts ...*ReplaceOptions) (*UpdateResult, error)
+
+	UpsertOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	UpsertId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	Upsert(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	UpdateId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error)
+
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error)
+
+	DeleteId(ctx context.Context, id interface{}, opts ...*DeleteOptions) (*DeleteResult, error)
+
+	DeleteMany(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error)
+
+	Find(ctx context.Context, filter interface{}) TypedQuery[T]
+
+	FindId(ctx context.Context, id interface{}) (T, error)
+
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}) TypedFindUpdate[T]
+
+	FindOneAndReplace(ctx context.Context, filter interface{}, replacement T) TypedFindReplace[T]
+
+	FindOneAndDelete(ctx context.Context, filter interface{}) TypedFindDelete[T]
+
+	Aggregate(ctx context.Context, pipeline interface{}) TypedAggregate[T]
+}
+
+// Typed 基于 Collection 创建一个文档类型为 T 的 TypedCollection，T 一般为结构体或者结构体指针。
+func Typed[T any](c Collection) TypedCollection[T] {
+	return &typedCollection[T]{collection: c}
+}
+
+type typedCollection[T any] struct {
+	collection Collection
+}
+
+func (c *typedCollection[T]) Collection() Collection {
+	return c.collection
+}
+
+func (c *typedCollection[T]) Name() string {
+	return c.collection.Name()
+}
+
+func (c *typedCollection[T]) InsertOne(ctx context.Context, document T, opts ...*InsertOneOptions) (*InsertOneResult, error) {
+	return c.collection.InsertOne(ctx, document, opts...)
+}
+
+func (c *typedCollection[T]) InsertOneNx(ctx context.Context, filter interface{}, document T, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.InsertOneNx(ctx, filter, document, opts...)
+}
+
+func (c *typedCollection[T]) InsertMany(ctx context.Context, documents []T, opts ...*InsertManyOptions) (*InsertManyResult, error) {
+	var nDocuments = make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		nDocuments = append(nDocuments, document)
+	}
+	return c.collection.InsertMany(ctx, nDocuments, opts...)
+}
+
+func (c *typedCollection[T]) Insert(ctx context.Context, documents ...T) (*InsertManyResult, error) {
+	return c.InsertMany(ctx, documents)
+}
+
+func (c *typedCollection[T]) RepsertOne(ctx context.Context, filter interface{}, replacement T, opts ...*ReplaceOptions) (*UpdateResult, error) {
+	return c.collection.RepsertOne(ctx, filter, replacement, opts...)
+}
+
+func (c *typedCollection[T]) ReplaceOne(ctx context.Context, filter interface{}, replacement T, opts ...*ReplaceOptions) (*UpdateResult, error) {
+	return c.collection.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+func (c *typedCollection[T]) UpsertOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.UpsertOne(ctx, filter, update, opts...)
+}
+
+func (c *typedCollection[T]) UpsertId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.UpsertId(ctx, id, update, opts...)
+}
+
+func (c *typedCollection[T]) Upsert(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.Upsert(ctx, filter, update, opts...)
+}
+
+func (c *typedCollection[T]) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c *typedCollection[T]) UpdateId(ctx context.Context, id interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.UpdateId(ctx, id, update, opts...)
+}
+
+func (c *typedCollection[T]) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*UpdateOptions) (*UpdateResult, error) {
+	return c.collection.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (c *typedCollection[T]) DeleteOne(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
+	return c.collection.DeleteOne(ctx, filter, opts...)
+}
+
+func (c *typedCollection[T]) DeleteId(ctx context.Context, id interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
+	return c.collection.DeleteId(ctx, id, opts...)
+}
+
+func (c *typedCollection[T]) DeleteMany(ctx context.Context, filter interface{}, opts ...*DeleteOptions) (*DeleteResult, error) {
+	return c.collection.DeleteMany(ctx, filter, opts...)
+}
+
+func (c *typedCollection[T]) Find(ctx context.Context, filter interface{}) TypedQuery[T] {
+	return &typedQuery[T]{query: c.collection.Find(ctx, filter), ctx: ctx}
+}
+
+func (c *typedCollection[T]) FindId(ctx context.Context, id interface{}) (T, error) {
+	return c.Find(ctx, M{"_id": id}).One()
+}
+
+func (c *typedCollection[T]) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}) TypedFindUpdate[T] {
+	return &typedFindUpdate[T]{findUpdate: c.collection.FindOneAndUpdate(ctx, filter, update)}
+}
+
+func (c *typedCollection[T]) FindOneAndReplace(ctx context.Context, filter interface{}, replacement T) TypedFindReplace[T] {
+	return &typedFindReplace[T]{findReplace: c.collection.FindOneAndReplace(ctx, filter, replacement)}
+}
+
+func (c *typedCollection[T]) FindOneAndDelete(ctx context.Context, filter interface{}) TypedFindDelete[T] {
+	return &typedFindDelete[T]{findDelete: c.collection.FindOneAndDelete(ctx, filter)}
+}
+
+func (c *typedCollection[T]) Aggregate(ctx context.Context, pipeline interface{}) TypedAggregate[T] {
+	return &typedAggregate[T]{aggregate: c.collection.Aggregate(ctx, pipeline)}
+}
+
+type TypedQuery[T any] interface {
+	Query() Query
+
+	BatchSize(n int32) TypedQuery[T]
+
+	Hint(hint interface{}) TypedQuery[T]
+
+	Limit(n int64) TypedQuery[T]
+
+	Project(projection interface{}) TypedQuery[T]
+	Select(projection interface{}) TypedQuery[T]
+
+	Skip(n int64) TypedQuery[T]
+
+	Sort(fields ...string) TypedQuery[T]
+
+	AllowDiskUse(b bool) TypedQuery[T]
+
+	AllowPartialResults(b bool) TypedQuery[T]
+
+	Collation(c *Collation) TypedQuery[T]
+
+	Comment(s string) TypedQuery[T]
+
+	CursorType(cursorType CursorType) TypedQuery[T]
+
+	Max(m interface{}) TypedQuery[T]
+
+	MaxAwaitTime(d time.Duration) TypedQuery[T]
+
+	MaxTime(d time.Duration) TypedQuery[T]
+
+	Min(m interface{}) TypedQuery[T]
+
+	NoCursorTimeout(b bool) TypedQuery[T]
+
+	ReturnKey(b bool) TypedQuery[T]
+
+	ShowRecordId(b bool) TypedQuery[T]
+
+	One() (T, error)
+
+	All() ([]T, error)
+
+	Count() (int64, error)
+
+	Page(page, size int64) ([]T, int64, error)
+
+	PageFacet(page, size int64) ([]T, int64, error)
+
+	Paginate(after string, size int64) ([]T, string, error)
+
+	// EachBatch 每次读取最多 n 个文档并调用 fn，batch 的底层数组会被复用，fn 中不能保留 batch
+	EachBatch(n int, fn func(batch []T) error) error
+
+	Explain(verbosity ExplainVerbosity) (*ExplainResult, error)
+
+	Cursor() CursorOf[T]
+}
+
+type typedQuery[T any] struct {
+	query Query
+	ctx   context.Context
+}
+
+func (q *typedQuery[T]) Query() Query {
+	return q.query
+}
+
+func (q *typedQuery[T]) BatchSize(n int32) TypedQuery[T] {
+	q.query.BatchSize(n)
+	return q
+}
+
+func (q *typedQuery[T]) Hint(hint interface{}) TypedQuery[T] {
+	q.query.Hint(hint)
+	return q
+}
+
+func (q *typedQuery[T]) Limit(n int64) TypedQuery[T] {
+	q.query.Limit(n)
+	return q
+}
+
+func (q *typedQuery[T]) Project(projection interface{}) TypedQuery[T] {
+	q.query.Project(projection)
+	return q
+}
+
+func (q *typedQuery[T]) Select(projection interface{}) TypedQuery[T] {
+	q.query.Select(projection)
+	return q
+}
+
+func (q *typedQuery[T]) Skip(n int64) TypedQuery[T] {
+	q.query.Skip(n)
+	return q
+}
+
+func (q *typedQuery[T]) Sort(fields ...string) TypedQuery[T] {
+	q.query.Sort(fields...)
+	return q
+}
+
+func (q *typedQuery[T]) AllowDiskUse(b bool) TypedQuery[T] {
+	q.query.AllowDiskUse(b)
+	return q
+}
+
+func (q *typedQuery[T]) AllowPartialResults(b bool) TypedQuery[T] {
+	q.query.AllowPartialResults(b)
+	return q
+}
+
+func (q *typedQuery[T]) Collation(c *Collation) TypedQuery[T] {
+	q.query.Collation(c)
+	return q
+}
+
+func (q *typedQuery[T]) Comment(s string) TypedQuery[T] {
+	q.query.Comment(s)
+	return q
+}
+
+func (q *typedQuery[T]) CursorType(cursorType CursorType) TypedQuery[T] {
+	q.query.CursorType(cursorType)
+	return q
+}
+
+func (q *typedQuery[T]) Max(m interface{}) TypedQuery[T] {
+	q.query.Max(m)
+	return q
+}
+
+func (q *typedQuery[T]) MaxAwaitTime(d time.Duration) TypedQuery[T] {
+	q.query.MaxAwaitTime(d)
+	return q
+}
+
+func (q *typedQuery[T]) MaxTime(d time.Duration) TypedQuery[T] {
+	q.query.MaxTime(d)
+	return q
+}
+
+func (q *typedQuery[T]) Min(m interface{}) TypedQuery[T] {
+	q.query.Min(m)
+	return q
+}
+
+func (q *typedQuery[T]) NoCursorTimeout(b bool) TypedQuery[T] {
+	q.query.NoCursorTimeout(b)
+	return q
+}
+
+func (q *typedQuery[T]) ReturnKey(b bool) TypedQuery[T] {
+	q.query.ReturnKey(b)
+	return q
+}
+
+func (q *typedQuery[T]) ShowRecordId(b bool) TypedQuery[T] {
+	q.query.ShowRecordId(b)
+	return q
+}
+
+func (q *typedQuery[T]) One() (T, error) {
+	var result T
+	var err = q.query.One(&result)
+	return result, err
+}
+
+func (q *typedQuery[T]) All() ([]T, error) {
+	var result []T
+	if err := q.query.All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (q *typedQuery[T]) Count() (int64, error) {
+	return q.query.Count()
+}
+
+func (q *typedQuery[T]) Page(page, size int64) ([]T, int64, error) {
+	var result []T
+	var total, err = q.query.Page(page, size, &result)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (q *typedQuery[T]) PageFacet(page, size int64) ([]T, int64, error) {
+	var result []T
+	var total, err = q.query.PageFacet(page, size, &result)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (q *typedQuery[T]) Paginate(after string, size int64) ([]T, string, error) {
+	var result []T
+	var next, err = q.query.Paginate(after, size, &result)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, next, nil
+}
+
+func (q *typedQuery[T]) EachBatch(n int, fn func(batch []T) error) error {
+	return q.Cursor().EachBatch(q.ctx, n, fn)
+}
+
+func (q *typedQuery[T]) Explain(verbosity ExplainVerbosity) (*ExplainResult, error) {
+	return q.query.Explain(verbosity)
+}
+
+func (q *typedQuery[T]) Cursor() CursorOf[T] {
+	return NewCursorOf[T](q.query.Cursor())
+}
+
+type TypedFindUpdate[T any] interface {
+	ArrayFilters(filters ArrayFilters) TypedFindUpdate[T]
+
+	BypassDocumentValidation(b bool) TypedFindUpdate[T]
+
+	Collation(c *Collation) TypedFindUpdate[T]
+
+	MaxTime(d time.Duration) TypedFindUpdate[T]
+
+	Project(projection interface{}) TypedFindUpdate[T]
+
+	ReturnDocument(rd ReturnDocument) TypedFindUpdate[T]
+
+	Sort(fields ...string) TypedFindUpdate[T]
+
+	Upsert(b bool) TypedFindUpdate[T]
+
+	Hint(hint interface{}) TypedFindUpdate[T]
+
+	Apply() (T, error)
+}
+
+type typedFindUpdate[T any] struct {
+	findUpdate FindUpdate
+}
+
+func (fu *typedFindUpdate[T]) ArrayFilters(filters ArrayFilters) TypedFindUpdate[T] {
+	fu.findUpdate.ArrayFilters(filters)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) BypassDocumentValidation(b bool) TypedFindUpdate[T] {
+	fu.findUpdate.BypassDocumentValidation(b)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Collation(c *Collation) TypedFindUpdate[T] {
+	fu.findUpdate.Collation(c)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) MaxTime(d time.Duration) TypedFindUpdate[T] {
+	fu.findUpdate.MaxTime(d)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Project(projection interface{}) TypedFindUpdate[T] {
+	fu.findUpdate.Project(projection)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) ReturnDocument(rd ReturnDocument) TypedFindUpdate[T] {
+	fu.findUpdate.ReturnDocument(rd)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Sort(fields ...string) TypedFindUpdate[T] {
+	fu.findUpdate.Sort(fields...)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Upsert(b bool) TypedFindUpdate[T] {
+	fu.findUpdate.Upsert(b)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Hint(hint interface{}) TypedFindUpdate[T] {
+	fu.findUpdate.Hint(hint)
+	return fu
+}
+
+func (fu *typedFindUpdate[T]) Apply() (T, error) {
+	var result T
+	var err = fu.findUpdate.Apply(&result)
+	return result, err
+}
+
+type TypedFindReplace[T any] interface {
+	BypassDocumentValidation(b bool) TypedFindReplace[T]
+
+	Collation(c *Collation) TypedFindReplace[T]
+
+	MaxTime(d time.Duration) TypedFindReplace[T]
+
+	Project(projection interface{}) TypedFindReplace[T]
+
+	ReturnDocument(rd ReturnDocument) TypedFindReplace[T]
+
+	Sort(fields ...string) TypedFindReplace[T]
+
+	Upsert(b bool) TypedFindReplace[T]
+
+	Hint(hint interface{}) TypedFindReplace[T]
+
+	Apply() (T, error)
+}
+
+type typedFindReplace[T any] struct {
+	findReplace FindReplace
+}
+
+func (fr *typedFindReplace[T]) BypassDocumentValidation(b bool) TypedFindReplace[T] {
+	fr.findReplace.BypassDocumentValidation(b)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Collation(c *Collation) TypedFindReplace[T] {
+	fr.findReplace.Collation(c)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) MaxTime(d time.Duration) TypedFindReplace[T] {
+	fr.findReplace.MaxTime(d)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Project(projection interface{}) TypedFindReplace[T] {
+	fr.findReplace.Project(projection)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) ReturnDocument(rd ReturnDocument) TypedFindReplace[T] {
+	fr.findReplace.ReturnDocument(rd)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Sort(fields ...string) TypedFindReplace[T] {
+	fr.findReplace.Sort(fields...)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Upsert(b bool) TypedFindReplace[T] {
+	fr.findReplace.Upsert(b)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Hint(hint interface{}) TypedFindReplace[T] {
+	fr.findReplace.Hint(hint)
+	return fr
+}
+
+func (fr *typedFindReplace[T]) Apply() (T, error) {
+	var result T
+	var err = fr.findReplace.Apply(&result)
+	return result, err
+}
+
+type TypedFindDelete[T any] interface {
+	Collation(c *Collation) TypedFindDelete[T]
+
+	MaxTime(d time.Duration) TypedFindDelete[T]
+
+	Project(projection interface{}) TypedFindDelete[T]
+
+	Sort(fields ...string) TypedFindDelete[T]
+
+	Hint(hint interface{}) TypedFindDelete[T]
+
+	Apply() (T, error)
+}
+
+type typedFindDelete[T any] struct {
+	findDelete FindDelete
+}
+
+func (fd *typedFindDelete[T]) Collation(c *Collation) TypedFindDelete[T] {
+	fd.findDelete.Collation(c)
+	return fd
+}
+
+func (fd *typedFindDelete[T]) MaxTime(d time.Duration) TypedFindDelete[T] {
+	fd.findDelete.MaxTime(d)
+	return fd
+}
+
+func (fd *typedFindDelete[T]) Project(projection interface{}) TypedFindDelete[T] {
+	fd.findDelete.Project(projection)
+	return fd
+}
+
+func (fd *typedFindDelete[T]) Sort(fields ...string) TypedFindDelete[T] {
+	fd.findDelete.Sort(fields...)
+	return fd
+}
+
+func (fd *typedFindDelete[T]) Hint(hint interface{}) TypedFindDelete[T] {
+	fd.findDelete.Hint(hint)
+	return fd
+}
+
+func (fd *typedFindDelete[T]) Apply() (T, error) {
+	var result T
+	var err = fd.findDelete.Apply(&result)
+	return result, err
+}
+
+type TypedAggregate[T any] interface {
+	AllowDiskUse(b bool) TypedAggregate[T]
+
+	BatchSize(n int32) TypedAggregate[T]
+
+	BypassDocumentValidation(b bool) TypedAggregate[T]
+
+	Collation(c *Collation) TypedAggregate[T]
+
+	Comment(s string) TypedAggregate[T]
+
+	Hint(hint interface{}) TypedAggregate[T]
+
+	MaxTime(d time.Duration) TypedAggregate[T]
+
+	MaxAwaitTime(d time.Duration) TypedAggregate[T]
+
+	One() (T, error)
+
+	All() ([]T, error)
+
+	Explain(verbosity ExplainVerbosity) (*ExplainResult, error)
+
+	Cursor() CursorOf[T]
+}
+
+type typedAggregate[T any] struct {
+	aggregate Aggregate
+}
+
+func (ag *typedAggregate[T]) AllowDiskUse(b bool) TypedAggregate[T] {
+	ag.aggregate.AllowDiskUse(b)
+	return ag
+}
+
+func (ag *typedAggregate[T]) BatchSize(n int32) TypedAggregate[T] {
+	ag.aggregate.BatchSize(n)
+	return ag
+}
+
+func (ag *typedAggregate[T]) BypassDocumentValidation(b bool) TypedAggregate[T] {
+	ag.aggregate.BypassDocumentValidation(b)
+	return ag
+}
+
+func (ag *typedAggregate[T]) Collation(c *Collation) TypedAggregate[T] {
+	ag.aggregate.Collation(c)
+	return ag
+}
+
+func (ag *typedAggregate[T]) Comment(s string) TypedAggregate[T] {
+	ag.aggregate.Comment(s)
+	return ag
+}
+
+func (ag *typedAggregate[T]) Hint(hint interface{}) TypedAggregate[T] {
+	ag.aggregate.Hint(hint)
+	return ag
+}
+
+func (ag *typedAggregate[T]) MaxTime(d time.Duration) TypedAggregate[T] {
+	ag.aggregate.MaxTime(d)
+	return ag
+}
+
+func (ag *typedAggregate[T]) MaxAwaitTime(d time.Duration) TypedAggregate[T] {
+	ag.aggregate.MaxAwaitTime(d)
+	return ag
+}
+
+func (ag *typedAggregate[T]) One() (T, error) {
+	var result T
+	var err = ag.aggregate.One(&result)
+	return result, err
+}
+
+func (ag *typedAggregate[T]) All() ([]T, error) {
+	var result []T
+	if err := ag.aggregate.All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (ag *typedAggregate[T]) Explain(verbosity ExplainVerbosity) (*ExplainResult, error) {
+	return ag.aggregate.Explain(verbosity)
+}
+
+func (ag *typedAggregate[T]) Cursor() CursorOf[T] {
+	return NewCursorOf[T](ag.aggregate.Cursor())
+}
+
+// DistinctValues 执行 Distinct 操作，并将结果解析为 []V。
+func DistinctValues[V any](d Distinct) ([]V, error) {
+	var result []V
+	if err := d.Apply(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,367 @@
+package dbm
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Update 用于构建更新文档，可以用于 Collection.UpdateOne、Collection.UpsertId、Bulk.UpdateMany、Collection.FindOneAndUpdate 等方法。
+//
+//	var update = dbm.U().Set("name", "smartwalle").Inc("count", 1).Push("tags", "go").Unset("temp").CurrentDate("updated_at")
+//
+// Update 生成的文档总是由更新操作符组成，不会被当作替换文档使用。
+type Update D
+
+func U() *Update {
+	return &Update{}
+}
+
+// D 返回构建的更新文档
+func (u *Update) D() D {
+	var d = make(D, len(*u))
+	copy(d, *u)
+	return d
+}
+
+// Op 为字段 key 添加更新操作符 op，可以用于添加 Update 没有提供的操作符
+func (u *Update) Op(key, op string, value interface{}) *Update {
+	for i := range *u {
+		var elem = &(*u)[i]
+		if elem.Key != op {
+			continue
+		}
+		var fields, ok = updateFields(elem.Value)
+		if !ok {
+			// 无法转换为文档的值会被覆盖
+			elem.Value = D{{Key: key, Value: value}}
+			return u
+		}
+		for j := range fields {
+			if fields[j].Key == key {
+				fields[j].Value = value
+				return u
+			}
+		}
+		elem.Value = append(fields, E{Key: key, Value: value})
+		return u
+	}
+	*u = append(*u, E{Key: op, Value: D{{Key: key, Value: value}}})
+	return u
+}
+
+// updateFields 将操作符已经存在的值转换为 D，值可能是 M 或者结构体等类型
+func updateFields(value interface{}) (D, bool) {
+	switch v := value.(type) {
+	case D:
+		return v, true
+	case nil:
+		return D{}, true
+	case M:
+		var keys = make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var fields = make(D, 0, len(v))
+		for _, key := range keys {
+			fields = append(fields, E{Key: key, Value: v[key]})
+		}
+		return fields, true
+	}
+
+	var data, err = bson.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var fields D
+	if err = bson.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+func (u *Update) Set(key string, value interface{}) *Update {
+	return u.Op(key, "$set", value)
+}
+
+func (u *Update) SetOnInsert(key string, value interface{}) *Update {
+	return u.Op(key, "$setOnInsert", value)
+}
+
+func (u *Update) Unset(keys ...string) *Update {
+	for _, key := range keys {
+		u.Op(key, "$unset", "")
+	}
+	return u
+}
+
+func (u *Update) Inc(key string, value interface{}) *Update {
+	return u.Op(key, "$inc", value)
+}
+
+func (u *Update) Mul(key string, value interface{}) *Update {
+	return u.Op(key, "$mul", value)
+}
+
+func (u *Update) Min(key string, value interface{}) *Update {
+	return u.Op(key, "$min", value)
+}
+
+func (u *Update) Max(key string, value interface{}) *Update {
+	return u.Op(key, "$max", value)
+}
+
+func (u *Update) Rename(key, newKey string) *Update {
+	return u.Op(key, "$rename", newKey)
+}
+
+// CurrentDate 将字段设置为当前时间（Date 类型）
+func (u *Update) CurrentDate(keys ...string) *Update {
+	for _, key := range keys {
+		u.Op(key, "$currentDate", true)
+	}
+	return u
+}
+
+// CurrentTimestamp 将字段设置为当前时间（Timestamp 类型）
+func (u *Update) CurrentTimestamp(keys ...string) *Update {
+	for _, key := range keys {
+		u.Op(key, "$currentDate", D{{Key: "$type", Value: "timestamp"}})
+	}
+	return u
+}
+
+func (u *Update) Push(key string, value interface{}) *Update {
+	return u.Op(key, "$push", value)
+}
+
+// PushEach 添加多个元素，modifiers 为 $slice、$sort、$position 等修饰符，如 dbm.NE("$slice", -10)
+func (u *Update) PushEach(key string, values interface{}, modifiers ...E) *Update {
+	var value = D{{Key: "$each", Value: values}}
+	value = append(value, modifiers...)
+	return u.Op(key, "$push", value)
+}
+
+func (u *Update) AddToSet(key string, value interface{}) *Update {
+	return u.Op(key, "$addToSet", value)
+}
+
+func (u *Update) AddToSetEach(key string, values interface{}) *Update {
+	return u.Op(key, "$addToSet", D{{Key: "$each", Value: values}})
+}
+
+// Pop first 为 true 时删除第一个元素，否则删除最后一个元素
+func (u *Update) Pop(key string, first bool) *Update {
+	if first {
+		return u.Op(key, "$pop", -1)
+	}
+	return u.Op(key, "$pop", 1)
+}
+
+// Pull 删除数组中等于 value 或者满足条件 value 的元素
+func (u *Update) Pull(key string, value interface{}) *Update {
+	return u.Op(key, "$pull", value)
+}
+
+// PullAll values 为 slice 或者 array
+func (u *Update) PullAll(key string, values interface{}) *Update {
+	return u.Op(key, "$pullAll", values)
+}
+
+// Positional 返回更新查询条件匹配到的第一个数组元素的路径，如 Positional("grades", "score") 返回 grades.$.score
+func Positional(array string, fields ...string) string {
+	return positionalPath(array, "$", fields)
+}
+
+// AllPositional 返回更新所有数组元素的路径，如 AllPositional("grades", "score") 返回 grades.$[].score
+func AllPositional(array string, fields ...string) string {
+	return positionalPath(array, "$[]", fields)
+}
+
+// FilteredPositional 返回更新满足 arrayFilters 条件的数组元素的路径，如 FilteredPositional("grades", "elem", "score") 返回 grades.$[elem].score，
+// 需要和 NewArrayFilters 一起使用
+func FilteredPositional(array, identifier string, fields ...string) string {
+	return positionalPath(array, "$["+identifier+"]", fields)
+}
+
+func positionalPath(array, operator string, fields []string) string {
+	var path = make([]string, 0, len(fields)+2)
+	path = append(path, array, operator)
+	path = append(path, fields...)
+	return strings.Join(path, ".")
+}
+
+// NewArrayFilters 创建 arrayFilters，用于 FindUpdate.ArrayFilters、UpdateOptions.SetArrayFilters 等
+//
+//	dbm.NewArrayFilters(dbm.M{"elem.score": dbm.M{"$gte": 90}})
+func NewArrayFilters(filters ...interface{}) ArrayFilters {
+	return ArrayFilters{Filters: filters}
+}
+
+type SetOptions struct {
+	// Registry 用于编码结构体，为 nil 时使用 bson.DefaultRegistry；Client 注册了自定义的编码器时需要传入 Client.Registry()，否则编码结果与写入时不一致
+	Registry *bsoncodec.Registry
+
+	// UnsetNil 为 true 时值为 nil 的字段会被 $unset，否则忽略这些字段
+	UnsetNil bool
+}
+
+func NewSetOptions() *SetOptions {
+	return &SetOptions{}
+}
+
+func (opts *SetOptions) SetRegistry(registry *bsoncodec.Registry) *SetOptions {
+	opts.Registry = registry
+	return opts
+}
+
+func (opts *SetOptions) SetUnsetNil(unset bool) *SetOptions {
+	opts.UnsetNil = unset
+	return opts
+}
+
+// SetFromStruct 将结构体转换为用于部分更新的 Update，可以用于 Collection.UpdateId、Collection.UpsertOne、Bulk.UpdateOne 等方法。
+//
+// 结构体按照 bson tag 编码，嵌套的结构体会被展开为 a.b.c 格式的路径，map、D 等其它类型的文档和数组作为整体更新；_id 字段会被忽略。
+// 带有 omitempty 的零值字段不会被更新，值为 nil 的字段默认会被忽略，可以通过 SetOptions.UnsetNil 将其 $unset。
+// 字段名包含 . 或者以 $ 开头时返回错误，没有需要更新的字段时返回 ErrEmptyUpdate。
+//
+// SetFromStruct 不会使用 Client 的 Registry，需要通过 SetOptions.SetRegistry(client.Registry()) 传入：
+//
+//	dbm.SetFromStruct(user, dbm.NewSetOptions().SetRegistry(db.Client().Registry()))
+func SetFromStruct(v interface{}, opts ...*SetOptions) (*Update, error) {
+	var nOpts = NewSetOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Registry != nil {
+			nOpts.Registry = opt.Registry
+		}
+		if opt.UnsetNil {
+			nOpts.UnsetNil = true
+		}
+	}
+	if nOpts.Registry == nil {
+		nOpts.Registry = bson.DefaultRegistry
+	}
+
+	var data, err = bson.MarshalWithRegistry(nOpts.Registry, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var update = U()
+	if err = flattenSet(update, "", bson.Raw(data), reflect.ValueOf(v), nOpts.UnsetNil); err != nil {
+		return nil, err
+	}
+	if len(*update) == 0 {
+		return nil, ErrEmptyUpdate
+	}
+	return update, nil
+}
+
+// flattenSet 将 doc 转换为 $set 和 $unset，value 为 doc 对应的结构体，只有类型为结构体的字段会被展开
+func flattenSet(update *Update, prefix string, doc bson.Raw, value reflect.Value, unsetNil bool) error {
+	var elements, err = doc.Elements()
+	if err != nil {
+		return err
+	}
+
+	var fields = make(map[string]reflect.Value)
+	if value, ok := structValue(value); ok {
+		if err = bsonFields(value, fields); err != nil {
+			return err
+		}
+	}
+
+	for _, element := range elements {
+		var key = element.Key()
+		if prefix == "" && key == "_id" {
+			continue
+		}
+		if key == "" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return fmt.Errorf("dbm: invalid field name %q", prefix+key)
+		}
+		var path = prefix + key
+		var elemValue = element.Value()
+
+		switch elemValue.Type {
+		case bsontype.Null, bsontype.Undefined:
+			if unsetNil {
+				update.Unset(path)
+			}
+		case bsontype.EmbeddedDocument:
+			var sub = elemValue.Document()
+			var field, ok = structValue(fields[key])
+			if _, err = sub.IndexErr(0); err != nil || !ok {
+				// 空文档或者不是结构体，直接设置
+				update.Set(path, sub)
+				continue
+			}
+			if err = flattenSet(update, path+".", sub, field, unsetNil); err != nil {
+				return err
+			}
+		default:
+			update.Set(path, elemValue)
+		}
+	}
+	return nil
+}
+
+var (
+	marshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	valueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+)
+
+// structValue 返回 value 指向的结构体，自定义了编码方式的结构体不会被展开
+func structValue(value reflect.Value) (reflect.Value, bool) {
+	for value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) {
+		if value.Type().Implements(marshalerType) || value.Type().Implements(valueMarshalerType) {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() || value.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	if reflect.PtrTo(value.Type()).Implements(marshalerType) || reflect.PtrTo(value.Type()).Implements(valueMarshalerType) {
+		return reflect.Value{}, false
+	}
+	return value, true
+}
+
+// bsonFields 按照 bson tag 的规则获取结构体字段编码之后的名称，inline 的结构体字段会被合并到当前结构体中
+func bsonFields(value reflect.Value, fields map[string]reflect.Value) error {
+	var valueType = value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		var field = valueType.Field(i)
+		// 与默认的 StructCodec 一致，忽略所有未导出的字段
+		if field.PkgPath != "" {
+			continue
+		}
+
+		var tags, err = bsoncodec.DefaultStructTagParser.ParseStructTags(field)
+		if err != nil {
+			return err
+		}
+		if tags.Skip {
+			continue
+		}
+		if tags.Inline {
+			if sub, ok := structValue(value.Field(i)); ok {
+				if err = bsonFields(sub, fields); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		fields[tags.Name] = value.Field(i)
+	}
+	return nil
+}
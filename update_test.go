@@ -0,0 +1,222 @@
+package dbm_test
+
+import (
+	"errors"
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"reflect"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	var tests = []struct {
+		update   *dbm.Update
+		expected string
+	}{
+		{
+			update:   dbm.U().Set("a", 1).Inc("n", 1).Set("b", "x").Set("a", 2),
+			expected: `{"$set":{"a":2,"b":"x"},"$inc":{"n":1}}`,
+		},
+		{
+			update:   dbm.U().Push("tags", "go").Unset("x", "y").CurrentDate("updated_at"),
+			expected: `{"$push":{"tags":"go"},"$unset":{"x":"","y":""},"$currentDate":{"updated_at":true}}`,
+		},
+		{
+			update:   dbm.U().PushEach("scores", []int{1, 2}, dbm.NE("$slice", -5)).Pop("queue", true),
+			expected: `{"$push":{"scores":{"$each":[1,2],"$slice":-5}},"$pop":{"queue":-1}}`,
+		},
+		{
+			update:   dbm.U().Set(dbm.Positional("grades", "score"), 1).Inc(dbm.AllPositional("grades"), 1).Set(dbm.FilteredPositional("grades", "elem", "mean"), 100),
+			expected: `{"$set":{"grades.$.score":1,"grades.$[elem].mean":100},"$inc":{"grades.$[]":1}}`,
+		},
+		{
+			update:   (&dbm.Update{{Key: "$set", Value: dbm.M{"b": 1, "a": 2}}}).Set("c", 3).Set("a", 4),
+			expected: `{"$set":{"a":4,"b":1,"c":3}}`,
+		},
+		{
+			update: (&dbm.Update{{Key: "$set", Value: struct {
+				Name string `bson:"name"`
+			}{Name: "smartwalle"}}}).Set("age", 10),
+			expected: `{"$set":{"name":"smartwalle","age":10}}`,
+		},
+		{
+			update:   (&dbm.Update{{Key: "$unset", Value: nil}, {Key: "$inc", Value: "invalid"}}).Unset("x").Inc("n", 1),
+			expected: `{"$unset":{"x":""},"$inc":{"n":1}}`,
+		},
+	}
+
+	for _, test := range tests {
+		var data, err = bson.MarshalExtJSON(test.update, false, false)
+		if err != nil {
+			t.Fatal("转换更新文档发生错误", err)
+		}
+		if string(data) != test.expected {
+			t.Fatalf("期望 %s，实际 %s", test.expected, string(data))
+		}
+	}
+}
+
+type SetAddress struct {
+	City   string `bson:"city"`
+	Street string `bson:"street,omitempty"`
+}
+
+type SetUser struct {
+	Id       string      `bson:"_id"`
+	Name     string      `bson:"name"`
+	Age      int         `bson:"age,omitempty"`
+	Tags     []string    `bson:"tags"`
+	Address  SetAddress  `bson:"address"`
+	Previous *SetAddress `bson:"previous"`
+}
+
+func TestSetFromStruct(t *testing.T) {
+	var user = &SetUser{Id: "1", Name: "smartwalle", Tags: []string{"go"}, Address: SetAddress{City: "GZ"}}
+
+	var tests = []struct {
+		opts     *dbm.SetOptions
+		expected string
+	}{
+		{
+			opts:     nil,
+			expected: `{"$set":{"name":"smartwalle","tags":["go"],"address.city":"GZ"}}`,
+		},
+		{
+			opts:     dbm.NewSetOptions().SetUnsetNil(true),
+			expected: `{"$set":{"name":"smartwalle","tags":["go"],"address.city":"GZ"},"$unset":{"previous":""}}`,
+		},
+	}
+
+	for _, test := range tests {
+		var update, err = dbm.SetFromStruct(user, test.opts)
+		if err != nil {
+			t.Fatal("转换结构体发生错误", err)
+		}
+		data, err := bson.MarshalExtJSON(update, false, false)
+		if err != nil {
+			t.Fatal("转换更新文档发生错误", err)
+		}
+		if string(data) != test.expected {
+			t.Fatalf("期望 %s，实际 %s", test.expected, string(data))
+		}
+	}
+}
+
+type SetVersion struct {
+	Version int `bson:"version"`
+}
+
+type SetProfile struct {
+	SetVersion `bson:",inline"`
+	Extra      dbm.M       `bson:"extra"`
+	Labels     dbm.D       `bson:"labels"`
+	Address    *SetAddress `bson:"address"`
+	Any        interface{} `bson:"any"`
+}
+
+func TestSetFromStruct_Document(t *testing.T) {
+	var profile = &SetProfile{
+		SetVersion: SetVersion{Version: 2},
+		Extra:      dbm.M{"x.y": 1},
+		Labels:     dbm.D{{Key: "$z", Value: 2}},
+		Address:    &SetAddress{City: "GZ"},
+		Any:        SetAddress{City: "SZ"},
+	}
+
+	// 只有结构体会被展开，map 和 D 作为整体更新
+	var update, err = dbm.SetFromStruct(profile)
+	if err != nil {
+		t.Fatal("转换结构体发生错误", err)
+	}
+	data, err := bson.MarshalExtJSON(update, false, false)
+	if err != nil {
+		t.Fatal("转换更新文档发生错误", err)
+	}
+	var expected = `{"$set":{"version":2,"extra":{"x.y":1},"labels":{"$z":2},"address.city":"GZ","any.city":"SZ"}}`
+	if string(data) != expected {
+		t.Fatalf("期望 %s，实际 %s", expected, string(data))
+	}
+
+	var tests = []interface{}{
+		dbm.M{"x.y": 1},
+		dbm.D{{Key: "$z", Value: 2}},
+		struct {
+			A struct {
+				B int `bson:"b.c"`
+			} `bson:"a"`
+		}{},
+	}
+	for _, test := range tests {
+		if _, err = dbm.SetFromStruct(test); err == nil {
+			t.Fatal("字段名包含 . 或者以 $ 开头时应该返回错误", test)
+		}
+	}
+
+	if _, err = dbm.SetFromStruct(&SetUser{Id: "1"}, dbm.NewSetOptions()); err != nil {
+		t.Fatal("转换结构体发生错误", err)
+	}
+	var empty = struct {
+		Id   string `bson:"_id"`
+		Name string `bson:"name,omitempty"`
+	}{Id: "1"}
+	if _, err = dbm.SetFromStruct(empty); !errors.Is(err, dbm.ErrEmptyUpdate) {
+		t.Fatal("没有需要更新的字段时应该返回 ErrEmptyUpdate", err)
+	}
+}
+
+type setHidden struct {
+	Secret SetAddress `bson:"secret"`
+}
+
+type SetCents struct {
+	N int64
+}
+
+type SetOrder struct {
+	setHidden
+	Extra  map[string]interface{} `bson:",inline"`
+	Price  SetCents               `bson:"price"`
+	Ignore SetAddress             `bson:"-"`
+}
+
+func TestSetFromStruct_Tags(t *testing.T) {
+	var order = &SetOrder{
+		setHidden: setHidden{Secret: SetAddress{City: "GZ"}},
+		Extra:     map[string]interface{}{"meta": dbm.M{"a": 1}},
+		Price:     SetCents{N: 100},
+		Ignore:    SetAddress{City: "SZ"},
+	}
+
+	// 未导出的嵌入字段和 - 会被忽略，inline 的 map 中的文档作为整体更新
+	var update, err = dbm.SetFromStruct(order)
+	if err != nil {
+		t.Fatal("转换结构体发生错误", err)
+	}
+	data, err := bson.MarshalExtJSON(update, false, false)
+	if err != nil {
+		t.Fatal("转换更新文档发生错误", err)
+	}
+	var expected = `{"$set":{"price.n":100,"meta":{"a":1}}}`
+	if string(data) != expected {
+		t.Fatalf("期望 %s，实际 %s", expected, string(data))
+	}
+
+	// 使用自定义的 Registry 编码
+	var registry = bson.NewRegistry()
+	registry.RegisterTypeEncoder(reflect.TypeOf(SetCents{}), bsoncodec.ValueEncoderFunc(func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+		return vw.WriteInt64(val.Interface().(SetCents).N)
+	}))
+	update, err = dbm.SetFromStruct(order, dbm.NewSetOptions().SetRegistry(registry))
+	if err != nil {
+		t.Fatal("转换结构体发生错误", err)
+	}
+	if data, err = bson.MarshalExtJSON(update, false, false); err != nil {
+		t.Fatal("转换更新文档发生错误", err)
+	}
+	expected = `{"$set":{"price":100,"meta":{"a":1}}}`
+	if string(data) != expected {
+		t.Fatalf("期望 %s，实际 %s", expected, string(data))
+	}
+}
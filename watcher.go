@@ -0,0 +1,314 @@
+package dbm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"sync"
+	"time"
+)
+
+// Watchable 可以被监听的对象，Client、Database 和 Collection 都实现了该接口。
+type Watchable interface {
+	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error)
+}
+
+// ResumeToken 是 Watcher 保存的 Change Stream 恢复位置。
+type ResumeToken struct {
+	Token Raw `bson:"token"`
+
+	// StartAfter 为 true 时 Token 为 invalidate 事件的 token，需要使用 startAfter 重新打开 Change Stream
+	StartAfter bool `bson:"start_after"`
+}
+
+// TokenStore 用于持久化 Change Stream 的 resume token。
+type TokenStore interface {
+	// Load 加载 key 对应的 token，不存在时返回 nil
+	Load(ctx context.Context, key string) (*ResumeToken, error)
+
+	Save(ctx context.Context, key string, token *ResumeToken) error
+}
+
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]ResumeToken
+}
+
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{tokens: make(map[string]ResumeToken)}
+}
+
+func (s *memoryTokenStore) Load(ctx context.Context, key string) (*ResumeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var token, ok = s.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (s *memoryTokenStore) Save(ctx context.Context, key string, token *ResumeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = *token
+	return nil
+}
+
+type collectionTokenStore struct {
+	collection Collection
+}
+
+// NewCollectionTokenStore 将 token 保存在 collection 中，文档的 _id 为 key。
+func NewCollectionTokenStore(collection Collection) TokenStore {
+	return &collectionTokenStore{collection: collection}
+}
+
+func (s *collectionTokenStore) Load(ctx context.Context, key string) (*ResumeToken, error) {
+	var token *ResumeToken
+	if err := s.collection.Find(ctx, M{"_id": key}).One(&token); err != nil {
+		if errors.Is(err, ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *collectionTokenStore) Save(ctx context.Context, key string, token *ResumeToken) error {
+	_, err := s.collection.UpsertId(ctx, key, M{"$set": M{"token": token.Token, "start_after": token.StartAfter, "updated_at": time.Now()}})
+	return err
+}
+
+// WatchHandler 处理 Change Stream 中的一个事件，可以通过 stream.Decode 解析事件。
+//
+// 返回 nil 之后该事件的 resume token 才会被保存；返回错误时 Watcher 停止运行，下次运行时会重新收到该事件。
+//
+// 没有事件时 Watcher 也会保存每一批数据的 post-batch resume token，重连时不会丢失两次保存之间的事件。
+type WatchHandler func(ctx context.Context, stream *ChangeStream) error
+
+type WatcherOptions struct {
+	Pipeline      interface{}
+	StreamOptions *ChangeStreamOptions
+
+	// MinBackoff 和 MaxBackoff 为重连的最小和最大等待时间，每次失败之后等待时间翻倍
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnError 在发生可恢复的错误并准备重连时调用
+	OnError func(err error)
+}
+
+func NewWatcherOptions() *WatcherOptions {
+	return &WatcherOptions{}
+}
+
+func (opts *WatcherOptions) SetPipeline(pipeline interface{}) *WatcherOptions {
+	opts.Pipeline = pipeline
+	return opts
+}
+
+func (opts *WatcherOptions) SetStreamOptions(streamOptions *ChangeStreamOptions) *WatcherOptions {
+	opts.StreamOptions = streamOptions
+	return opts
+}
+
+func (opts *WatcherOptions) SetBackoff(min, max time.Duration) *WatcherOptions {
+	opts.MinBackoff = min
+	opts.MaxBackoff = max
+	return opts
+}
+
+func (opts *WatcherOptions) SetOnError(fn func(err error)) *WatcherOptions {
+	opts.OnError = fn
+	return opts
+}
+
+// Watcher 在 Change Stream 的基础上提供断线重连和 resume token 持久化，事件至少会被处理一次。
+type Watcher interface {
+	// Run 持续监听并处理事件，直到 ctx 被取消、handler 返回错误或者发生不可恢复的错误
+	Run(ctx context.Context, handler WatchHandler) error
+}
+
+type watcher struct {
+	target Watchable
+	key    string
+	store  TokenStore
+	opts   *WatcherOptions
+}
+
+func NewWatcher(target Watchable, key string, store TokenStore, opts ...*WatcherOptions) Watcher {
+	var nOpts = NewWatcherOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Pipeline != nil {
+			nOpts.Pipeline = opt.Pipeline
+		}
+		if opt.StreamOptions != nil {
+			nOpts.StreamOptions = opt.StreamOptions
+		}
+		if opt.MinBackoff > 0 {
+			nOpts.MinBackoff = opt.MinBackoff
+		}
+		if opt.MaxBackoff > 0 {
+			nOpts.MaxBackoff = opt.MaxBackoff
+		}
+		if opt.OnError != nil {
+			nOpts.OnError = opt.OnError
+		}
+	}
+	if nOpts.Pipeline == nil {
+		nOpts.Pipeline = mongo.Pipeline{}
+	}
+	if nOpts.MinBackoff <= 0 {
+		nOpts.MinBackoff = 100 * time.Millisecond
+	}
+	if nOpts.MaxBackoff <= 0 {
+		nOpts.MaxBackoff = 30 * time.Second
+	}
+	if nOpts.MaxBackoff < nOpts.MinBackoff {
+		nOpts.MaxBackoff = nOpts.MinBackoff
+	}
+	return &watcher{target: target, key: key, store: store, opts: nOpts}
+}
+
+func (w *watcher) Run(ctx context.Context, handler WatchHandler) error {
+	var token, err = w.store.Load(ctx, w.key)
+	if err != nil {
+		return err
+	}
+
+	var backoff = w.opts.MinBackoff
+	for {
+		var stream *ChangeStream
+		var invalidated bool
+		stream, err = w.target.Watch(ctx, w.opts.Pipeline, w.streamOptions(token))
+		if err == nil {
+			var received, stop bool
+			received, invalidated, stop, err = w.consume(ctx, stream, handler, &token)
+			stream.Close(context.Background())
+			if stop {
+				return err
+			}
+			if received {
+				backoff = w.opts.MinBackoff
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			// 收到 invalidate 事件之后 Change Stream 会被关闭，使用 startAfter 立即重新打开
+			if invalidated {
+				continue
+			}
+		} else {
+			if !isResumableError(err) {
+				return err
+			}
+			if w.opts.OnError != nil {
+				w.opts.OnError(err)
+			}
+		}
+
+		var timer = time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > w.opts.MaxBackoff {
+			backoff = w.opts.MaxBackoff
+		}
+	}
+}
+
+// consume 处理 stream 中的事件，每处理一个事件或者收到一个空的批次之后保存 resume token；
+// 收到 invalidate 事件时 invalidated 为 true，handler 或者 TokenStore 返回错误时 stop 为 true
+func (w *watcher) consume(ctx context.Context, stream *ChangeStream, handler WatchHandler, token **ResumeToken) (received, invalidated, stop bool, err error) {
+	// 打开 Change Stream 时第一批数据为空，保存 post-batch resume token，避免在收到第一个事件之前重连时从当前时间开始监听
+	if err = w.save(ctx, token, stream.ResumeToken(), false); err != nil {
+		return received, false, true, err
+	}
+
+	for {
+		if !stream.TryNext(ctx) {
+			if err = stream.Err(); err != nil {
+				return received, false, false, err
+			}
+			if stream.ID() == 0 {
+				return received, false, false, nil
+			}
+			if err = w.save(ctx, token, stream.ResumeToken(), false); err != nil {
+				return received, false, true, err
+			}
+			continue
+		}
+
+		received = true
+		if err = handler(ctx, stream); err != nil {
+			return received, false, true, err
+		}
+
+		var operationType, _ = stream.Current.Lookup("operationType").StringValueOK()
+		if operationType == OperationTypeInvalidate {
+			// invalidate 事件之后只能通过该事件的 _id 使用 startAfter 重新打开
+			var id, _ = stream.Current.Lookup("_id").DocumentOK()
+			if err = w.save(ctx, token, id, true); err != nil {
+				return received, false, true, err
+			}
+			return received, true, false, nil
+		}
+
+		if err = w.save(ctx, token, stream.ResumeToken(), false); err != nil {
+			return received, false, true, err
+		}
+	}
+}
+
+// save 保存新的 resume token，token 没有发生变化时不会重复保存
+func (w *watcher) save(ctx context.Context, token **ResumeToken, raw Raw, startAfter bool) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if current := *token; current != nil && current.StartAfter == startAfter && bytes.Equal(current.Token, raw) {
+		return nil
+	}
+
+	var nToken = &ResumeToken{Token: Raw(append([]byte(nil), raw...)), StartAfter: startAfter}
+	if err := w.store.Save(ctx, w.key, nToken); err != nil {
+		return err
+	}
+	*token = nToken
+	return nil
+}
+
+func (w *watcher) streamOptions(token *ResumeToken) *ChangeStreamOptions {
+	var opts = options.MergeChangeStreamOptions(w.opts.StreamOptions)
+	if token != nil {
+		opts.ResumeAfter = nil
+		opts.StartAfter = nil
+		opts.StartAtOperationTime = nil
+		if token.StartAfter {
+			opts.SetStartAfter(token.Token)
+		} else {
+			opts.SetResumeAfter(token.Token)
+		}
+	}
+	return opts
+}
+
+func isResumableError(err error) bool {
+	if IsNetworkError(err) || IsTimeout(err) || hasErrorLabel(err, "ResumableChangeStreamError") {
+		return true
+	}
+	var ssErr topology.ServerSelectionError
+	return errors.As(err, &ssErr)
+}
@@ -0,0 +1,247 @@
+package dbm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/smartwalle/dbm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errStopWatch = errors.New("stop watch")
+
+// fakeWatchable 记录每次 Watch 使用的选项，errs 中第 n 个元素不为 nil 时第 n 次 Watch 返回该错误，否则通过 mock 服务器打开 Change Stream
+type fakeWatchable struct {
+	mt   *mtest.T
+	errs []error
+	opts []*options.ChangeStreamOptions
+}
+
+func (w *fakeWatchable) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*dbm.ChangeStream, error) {
+	var n = len(w.opts)
+	w.opts = append(w.opts, options.MergeChangeStreamOptions(opts...))
+	if n < len(w.errs) && w.errs[n] != nil {
+		return nil, w.errs[n]
+	}
+	return w.mt.Coll.Watch(ctx, pipeline, opts...)
+}
+
+// recordTokenStore 记录所有保存过的 token
+type recordTokenStore struct {
+	dbm.TokenStore
+	mu     sync.Mutex
+	tokens []dbm.ResumeToken
+}
+
+func (s *recordTokenStore) Save(ctx context.Context, key string, token *dbm.ResumeToken) error {
+	s.mu.Lock()
+	s.tokens = append(s.tokens, *token)
+	s.mu.Unlock()
+	return s.TokenStore.Save(ctx, key, token)
+}
+
+func newRecordTokenStore() *recordTokenStore {
+	return &recordTokenStore{TokenStore: dbm.NewMemoryTokenStore()}
+}
+
+func resumeToken(data string) dbm.Raw {
+	var raw, _ = bson.Marshal(dbm.M{"_data": data})
+	return raw
+}
+
+func changeEvent(data, operationType string) bson.D {
+	return bson.D{{Key: "_id", Value: dbm.M{"_data": data}}, {Key: "operationType", Value: operationType}}
+}
+
+func changeStreamResponse(id int64, batch string, pbrt string, events ...bson.D) bson.D {
+	var docs = bson.A{}
+	for _, event := range events {
+		docs = append(docs, event)
+	}
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "cursor", Value: bson.D{
+			{Key: "id", Value: id},
+			{Key: "ns", Value: "db.coll"},
+			{Key: batch, Value: docs},
+			{Key: "postBatchResumeToken", Value: dbm.M{"_data": pbrt}},
+		}},
+	}
+}
+
+func sameToken(token dbm.ResumeToken, data string, startAfter bool) bool {
+	return bytes.Equal(token.Token, resumeToken(data)) && token.StartAfter == startAfter
+}
+
+func TestWatcher_PostBatchToken(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("empty batches", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			changeStreamResponse(1, "firstBatch", "P1"),
+			changeStreamResponse(1, "nextBatch", "P2"),
+			changeStreamResponse(1, "nextBatch", "P2"),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "stop"}),
+		)
+
+		var store = newRecordTokenStore()
+		var watcher = dbm.NewWatcher(&fakeWatchable{mt: mt}, "key", store)
+		var err = watcher.Run(context.Background(), func(ctx context.Context, stream *dbm.ChangeStream) error {
+			t.Fatal("不应该收到事件")
+			return nil
+		})
+		if err == nil {
+			t.Fatal("应该返回不可恢复的错误")
+		}
+
+		// 没有收到事件时也需要保存 post-batch resume token，相同的 token 不会重复保存
+		if len(store.tokens) != 2 || !sameToken(store.tokens[0], "P1", false) || !sameToken(store.tokens[1], "P2", false) {
+			t.Fatal("保存的 token 不匹配", store.tokens)
+		}
+	})
+}
+
+func TestWatcher_Invalidate(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("invalidate", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			changeStreamResponse(1, "firstBatch", "P1", changeEvent("E1", dbm.OperationTypeInsert)),
+			changeStreamResponse(0, "nextBatch", "P2", changeEvent("E2", dbm.OperationTypeInvalidate)),
+		)
+
+		var store = newRecordTokenStore()
+		var target = &fakeWatchable{mt: mt, errs: []error{nil, errStopWatch}}
+		var watcher = dbm.NewWatcher(target, "key", store)
+
+		var types []string
+		var err = watcher.Run(context.Background(), func(ctx context.Context, stream *dbm.ChangeStream) error {
+			var event dbm.ChangeEvent
+			if err := stream.Decode(&event); err != nil {
+				return err
+			}
+			types = append(types, string(event.OperationType))
+			return nil
+		})
+		if !errors.Is(err, errStopWatch) {
+			t.Fatal("应该返回 Watch 的错误", err)
+		}
+
+		if len(types) != 2 || types[0] != dbm.OperationTypeInsert || types[1] != dbm.OperationTypeInvalidate {
+			t.Fatal("收到的事件不匹配", types)
+		}
+		if len(store.tokens) != 2 || !sameToken(store.tokens[0], "P1", false) || !sameToken(store.tokens[1], "E2", true) {
+			t.Fatal("保存的 token 不匹配", store.tokens)
+		}
+
+		// invalidate 之后立即使用 startAfter 重新打开
+		if len(target.opts) != 2 || !bytes.Equal(target.opts[1].StartAfter.(dbm.Raw), resumeToken("E2")) || target.opts[1].ResumeAfter != nil {
+			t.Fatal("重新打开 Change Stream 的选项不匹配", target.opts)
+		}
+	})
+}
+
+func TestWatcher_StoredToken(t *testing.T) {
+	var tests = []struct {
+		token      dbm.ResumeToken
+		startAfter bool
+	}{
+		{token: dbm.ResumeToken{Token: resumeToken("T1")}, startAfter: false},
+		{token: dbm.ResumeToken{Token: resumeToken("T2"), StartAfter: true}, startAfter: true},
+	}
+
+	for _, test := range tests {
+		var store = dbm.NewMemoryTokenStore()
+		store.Save(context.Background(), "key", &test.token)
+
+		var streamOpts = dbm.NewChangeStreamOptions()
+		streamOpts.SetStartAtOperationTime(&dbm.Timestamp{T: 1})
+		var target = &fakeWatchable{errs: []error{errStopWatch}}
+		var watcher = dbm.NewWatcher(target, "key", store, dbm.NewWatcherOptions().SetStreamOptions(streamOpts))
+		if err := watcher.Run(context.Background(), nil); !errors.Is(err, errStopWatch) {
+			t.Fatal("应该返回 Watch 的错误", err)
+		}
+
+		var opts = target.opts[0]
+		if opts.StartAtOperationTime != nil {
+			t.Fatal("存在 token 时应该忽略 StartAtOperationTime")
+		}
+		if test.startAfter {
+			if opts.ResumeAfter != nil || !bytes.Equal(opts.StartAfter.(dbm.Raw), test.token.Token) {
+				t.Fatal("应该使用 startAfter", opts.StartAfter, opts.ResumeAfter)
+			}
+		} else {
+			if opts.StartAfter != nil || !bytes.Equal(opts.ResumeAfter.(dbm.Raw), test.token.Token) {
+				t.Fatal("应该使用 resumeAfter", opts.StartAfter, opts.ResumeAfter)
+			}
+		}
+	}
+}
+
+func TestWatcher_ResumableError(t *testing.T) {
+	var resumable = mongo.CommandError{Code: 6, Name: "HostUnreachable", Labels: []string{"ResumableChangeStreamError"}}
+	var target = &fakeWatchable{errs: []error{resumable, resumable, errStopWatch}}
+
+	var errs []error
+	var opts = dbm.NewWatcherOptions()
+	opts.SetBackoff(time.Millisecond, 2*time.Millisecond)
+	opts.SetOnError(func(err error) {
+		errs = append(errs, err)
+	})
+
+	var watcher = dbm.NewWatcher(target, "key", dbm.NewMemoryTokenStore(), opts)
+	if err := watcher.Run(context.Background(), nil); !errors.Is(err, errStopWatch) {
+		t.Fatal("应该返回不可恢复的错误", err)
+	}
+	if len(errs) != 2 || len(target.opts) != 3 {
+		t.Fatal("可恢复的错误应该重试", errs, len(target.opts))
+	}
+}
+
+func TestWatcher_Cancel(t *testing.T) {
+	var resumable = mongo.CommandError{Code: 6, Name: "HostUnreachable", Labels: []string{"ResumableChangeStreamError"}}
+	var target = &fakeWatchable{errs: []error{resumable, resumable}}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var opts = dbm.NewWatcherOptions()
+	opts.SetBackoff(time.Hour, time.Hour)
+	opts.SetOnError(func(err error) {
+		cancel()
+	})
+
+	var watcher = dbm.NewWatcher(target, "key", dbm.NewMemoryTokenStore(), opts)
+	if err := watcher.Run(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatal("ctx 被取消时应该返回 context.Canceled", err)
+	}
+	if len(target.opts) != 1 {
+		t.Fatal("ctx 被取消之后不应该重试", len(target.opts))
+	}
+}
+
+func TestWatcher_HandlerError(t *testing.T) {
+	var mt = mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("handler error", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			changeStreamResponse(0, "firstBatch", "P1", changeEvent("E1", dbm.OperationTypeInsert)),
+		)
+
+		var errHandler = errors.New("handler error")
+		var store = dbm.NewMemoryTokenStore()
+		var watcher = dbm.NewWatcher(&fakeWatchable{mt: mt}, "key", store)
+		var err = watcher.Run(context.Background(), func(ctx context.Context, stream *dbm.ChangeStream) error {
+			return errHandler
+		})
+		if !errors.Is(err, errHandler) {
+			t.Fatal("应该返回 handler 的错误", err)
+		}
+
+		// 处理失败的事件不会保存 token，下次运行时会重新收到该事件
+		if token, _ := store.Load(context.Background(), "key"); token != nil {
+			t.Fatal("不应该保存 token", token)
+		}
+	})
+}